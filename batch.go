@@ -0,0 +1,168 @@
+package syncmap
+
+// groupKeys buckets keys by shard index so batch operations lock every shard
+// at most once, always in ascending index order. The caller holds m.mu.
+func (m *SyncMap) groupKeys(keys []string) [][]string {
+	groups := make([][]string, m.shardCount)
+	for _, key := range keys {
+		idx := m.locateIndex(key)
+		groups[idx] = append(groups[idx], key)
+	}
+	return groups
+}
+
+func (m *SyncMap) MGet(keys []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(keys))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for idx, group := range m.groupKeys(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		shard := m.shards[idx]
+		shard.RLock()
+		for _, key := range group {
+			if value, ok := shard.GetNotLock(key); ok {
+				result[key] = value
+			}
+		}
+		shard.RUnlock()
+	}
+	return result
+}
+
+// MHas reports for every key whether it is present, like MGet without
+// copying the values.
+func (m *SyncMap) MHas(keys []string) map[string]bool {
+	result := make(map[string]bool, len(keys))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for idx, group := range m.groupKeys(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		shard := m.shards[idx]
+		shard.RLock()
+		for _, key := range group {
+			_, result[key] = shard.GetNotLock(key)
+		}
+		shard.RUnlock()
+	}
+	return result
+}
+
+// MSet writes all items, taking each shard's write lock exactly once in
+// ascending shard order.
+func (m *SyncMap) MSet(items map[string]interface{}) {
+	m.mu.RLock()
+	groups := make([][]Item, m.shardCount)
+	for key, value := range items {
+		idx := m.locateIndex(key)
+		groups[idx] = append(groups[idx], Item{key, value})
+	}
+	evicted := m.setGroups(groups, true, nil)
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+}
+
+// SetItems is MSet for a slice, a key given several times keeps the value of
+// its last occurrence.
+func (m *SyncMap) SetItems(items []Item) {
+	m.mu.RLock()
+	groups := make([][]Item, m.shardCount)
+	for _, item := range items {
+		idx := m.locateIndex(item.Key)
+		groups[idx] = append(groups[idx], item)
+	}
+	evicted := m.setGroups(groups, true, nil)
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+}
+
+// setGroups writes items bucketed by shard index, existing keys are only
+// replaced when overwrite is set and keys found in deadlines get that unix
+// nano expiry. The caller holds m.mu and reports the returned evictions once
+// it is released.
+func (m *SyncMap) setGroups(groups [][]Item, overwrite bool, deadlines map[string]int64) (evicted []Item) {
+	for idx, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		shard := m.shards[idx]
+		shard.Lock()
+		for _, item := range group {
+			if !overwrite {
+				if _, ok := shard.GetNotLock(item.Key); ok {
+					continue
+				}
+			}
+			shard.SetNotLock(item.Key, item.Value)
+			if deadline, ok := deadlines[item.Key]; ok {
+				if _, stored := shard.items[item.Key]; stored {
+					shard.setDeadlineNotLock(item.Key, deadline)
+				}
+			}
+		}
+		evicted = shard.unlockDeferred(evicted)
+	}
+	return evicted
+}
+
+// MDelete removes the given keys and returns how many were present, shards
+// are locked in the same order as MSet.
+func (m *SyncMap) MDelete(keys []string) int {
+	var (
+		removed int
+		evicted []Item
+	)
+	m.mu.RLock()
+	for idx, group := range m.groupKeys(keys) {
+		if len(group) == 0 {
+			continue
+		}
+		shard := m.shards[idx]
+		shard.Lock()
+		for _, key := range group {
+			if _, ok := shard.GetNotLock(key); ok {
+				shard.DeleteNotLock(key)
+				removed++
+			}
+		}
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+	return removed
+}
+
+// MGetOrdered is MGet aligned with keys: values[i] and found[i] describe
+// keys[i], a miss leaves a nil value.
+func (m *SyncMap) MGetOrdered(keys []string) (values []interface{}, found []bool) {
+	values = make([]interface{}, len(keys))
+	found = make([]bool, len(keys))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	positions := make([][]int, m.shardCount)
+	for i, key := range keys {
+		idx := m.locateIndex(key)
+		positions[idx] = append(positions[idx], i)
+	}
+	for idx, group := range positions {
+		if len(group) == 0 {
+			continue
+		}
+		shard := m.shards[idx]
+		shard.RLock()
+		for _, i := range group {
+			values[i], found[i] = shard.GetNotLock(keys[i])
+		}
+		shard.RUnlock()
+	}
+	return values, found
+}
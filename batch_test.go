@@ -0,0 +1,118 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func BenchmarkMGet(b *testing.B) {
+	m := fill(New(), 100000)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i * 97)
+	}
+
+	b.Run("MGet", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.MGet(keys)
+		}
+	})
+	b.Run("GetLoop", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			result := make(map[string]interface{}, len(keys))
+			for _, key := range keys {
+				if v, ok := m.Get(key); ok {
+					result[key] = v
+				}
+			}
+		}
+	})
+}
+
+func TestMSet(t *testing.T) {
+	items := make(map[string]interface{}, 10000)
+	for i := 0; i < 10000; i++ {
+		items[strconv.Itoa(i)] = i
+	}
+	m := New()
+	m.MSet(items)
+	if m.Size() != 10000 {
+		t.Fatalf("Size() = %d, want 10000", m.Size())
+	}
+	for _, i := range []int{0, 17, 4242, 9999} {
+		if v, ok := m.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("Get(%d) = %v, %v", i, v, ok)
+		}
+	}
+}
+
+func TestMDelete(t *testing.T) {
+	m := fill(New(), 100)
+	removed := m.MDelete([]string{"1", "2", "3", "missing", "2", "other"})
+	if removed != 3 {
+		t.Fatalf("MDelete removed %d, want 3", removed)
+	}
+	if m.Size() != 97 || m.Has("1") || !m.Has("4") {
+		t.Fatal("MDelete touched the wrong keys")
+	}
+}
+
+func TestSetItemsLastWriteWins(t *testing.T) {
+	m := New()
+	m.Set("a", 0)
+	m.SetItems([]Item{{"a", 1}, {"b", 1}, {"a", 2}, {"c", 1}, {"b", 2}, {"a", 3}})
+	want := map[string]interface{}{"a": 3, "b": 2, "c": 1}
+	if m.Size() != len(want) {
+		t.Fatalf("Size() = %d, want %d", m.Size(), len(want))
+	}
+	for key, value := range want {
+		if got, _ := m.Get(key); got != value {
+			t.Fatalf("Get(%s) = %v, want %v", key, got, value)
+		}
+	}
+	m.SetItems(nil)
+	if m.Size() != len(want) {
+		t.Fatal("SetItems(nil) changed the map")
+	}
+}
+
+func TestMGetOrdered(t *testing.T) {
+	m := New()
+	m.Set("a", 1)
+	m.Set("c", 3)
+	m.Set("nil", nil)
+
+	keys := []string{"a", "b", "c", "a", "nil", "d"}
+	values, found := m.MGetOrdered(keys)
+	wantValues := []interface{}{1, nil, 3, 1, nil, nil}
+	wantFound := []bool{true, false, true, true, true, false}
+	if len(values) != len(keys) || len(found) != len(keys) {
+		t.Fatalf("MGetOrdered returned %d values and %d flags for %d keys", len(values), len(found), len(keys))
+	}
+	for i := range keys {
+		if values[i] != wantValues[i] || found[i] != wantFound[i] {
+			t.Fatalf("position %d (%s) = %v, %v, want %v, %v", i, keys[i], values[i], found[i], wantValues[i], wantFound[i])
+		}
+	}
+}
+
+func TestMHas(t *testing.T) {
+	m := fill(New(), 100)
+	m.Set("nil", nil)
+	keys := []string{"0", "absent", "99", "100", "nil", "0"}
+	got := m.MHas(keys)
+	want := map[string]bool{"0": true, "absent": false, "99": true, "100": false, "nil": true}
+	if len(got) != len(want) {
+		t.Fatalf("MHas returned %d keys, want %d: %v", len(got), len(want), got)
+	}
+	for key, present := range want {
+		if got[key] != present {
+			t.Fatalf("MHas[%s] = %v, want %v", key, got[key], present)
+		}
+	}
+	if len(m.MHas(nil)) != 0 {
+		t.Fatal("MHas(nil) reported keys")
+	}
+}
@@ -0,0 +1,86 @@
+package syncmap
+
+import (
+	"time"
+)
+
+// Clone returns a map built with the same shards and options as m, such as
+// the hasher or the LRU bound, holding a copy of every live entry with its
+// ttl, version and metadata. Values are shared by reference, only the map
+// structure is copied. The OnEvicted callback, Watch subscriptions and the
+// janitor of NewWithExpiration are not carried over.
+func (m *SyncMap) Clone() *SyncMap {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clone := m.emptyLike()
+	clone.fillNotLock(m.shards, true, nil)
+	return clone
+}
+
+// Merge copies the live entries of other into m with their ttl, keys already
+// present in m are replaced only when overwrite is true. The shards of other
+// are copied one at a time before m is locked, so the two maps are never
+// locked together and may have different shard counts or hashers. The result
+// is written with a single lock per shard of m.
+func (m *SyncMap) Merge(other *SyncMap, overwrite bool) {
+	if other == nil || other == m {
+		return
+	}
+
+	var (
+		items     []Item
+		deadlines map[string]int64
+		now       = time.Now().UnixNano()
+	)
+	for _, shard := range other.GetShards() {
+		shard.RLock()
+		for key, value := range shard.items {
+			if shard.expiredNotLock(key, now) {
+				continue
+			}
+			items = append(items, Item{key, value})
+			if deadline, ok := shard.expires[key]; ok {
+				if deadlines == nil {
+					deadlines = make(map[string]int64)
+				}
+				deadlines[key] = deadline
+			}
+		}
+		shard.RUnlock()
+	}
+
+	m.mu.RLock()
+	groups := make([][]Item, m.shardCount)
+	for _, item := range items {
+		idx := m.locateIndex(item.Key)
+		groups[idx] = append(groups[idx], item)
+	}
+	evicted := m.setGroups(groups, overwrite, deadlines)
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+}
+
+// Equal reports whether both maps hold the same keys with equal values,
+// compared like CompareAndSwap. Each map is snapshotted shard by shard, so
+// the result is only meaningful while neither is being written.
+func (m *SyncMap) Equal(other *SyncMap) bool {
+	if other == nil {
+		return false
+	}
+	if other == m {
+		return true
+	}
+
+	mine, theirs := m.Items(), other.Items()
+	if len(mine) != len(theirs) {
+		return false
+	}
+	for key, value := range mine {
+		v, ok := theirs[key]
+		if !ok || !valuesEqual(value, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package syncmap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCloneIsIndependent(t *testing.T) {
+	m := fill(New(), 100)
+	clone := m.Clone()
+	if clone.ShardCount() != m.ShardCount() || !reflect.DeepEqual(clone.Items(), m.Items()) {
+		t.Fatal("clone differs from the original")
+	}
+
+	clone.Set("1", "changed")
+	clone.Delete("2")
+	clone.Set("new", true)
+	if v, _ := m.Get("1"); v != 1 {
+		t.Fatalf("original Get(1) = %v after writing the clone", v)
+	}
+	if !m.Has("2") || m.Has("new") || m.Size() != 100 {
+		t.Fatal("writing the clone changed the original")
+	}
+}
+
+func TestCloneKeepsOptions(t *testing.T) {
+	lru := fill(NewLRU(10, 4), 10)
+	clone := lru.Clone()
+	fill(clone, 100)
+	if n := clone.Size(); n > 10 {
+		t.Fatalf("clone of NewLRU(10, 4) holds %d entries", n)
+	}
+
+	m := New()
+	m.SetWithTTL("ttl", 1, time.Hour)
+	m.SetWithTTL("expired", 2, time.Nanosecond)
+	m.SetIfNewer("versioned", 3, 7)
+	time.Sleep(time.Millisecond)
+	clone = m.Clone()
+	if _, ttl, ok := clone.GetWithTTL("ttl"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("clone lost the ttl: %v, %v", ttl, ok)
+	}
+	if clone.Size() != 2 {
+		t.Fatalf("clone holds %d entries, want the expired one skipped", clone.Size())
+	}
+	if clone.SetIfNewer("versioned", 4, 7) {
+		t.Fatal("clone lost the version of the key")
+	}
+
+	meta := NewWithMetadata()
+	meta.Set("k", 1)
+	clone = meta.Clone()
+	clone.Get("k")
+	if _, _, _, hits, _ := meta.GetMeta("k"); hits != 0 {
+		t.Fatalf("reading the clone counted %d hits on the original", hits)
+	}
+	if _, _, _, hits, ok := clone.GetMeta("k"); !ok || hits != 1 {
+		t.Fatalf("clone metadata hits = %d, %v", hits, ok)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for _, overwrite := range []bool{true, false} {
+		m := NewWithShard(8)
+		m.Set("shared", "mine")
+		m.Set("only-mine", 1)
+		other := NewWithShard(64)
+		other.Set("shared", "theirs")
+		other.Set("only-theirs", 2)
+		other.SetWithTTL("ttl", 3, time.Hour)
+		other.SetWithTTL("expired", 4, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+
+		m.Merge(other, overwrite)
+		want := "mine"
+		if overwrite {
+			want = "theirs"
+		}
+		if v, _ := m.Get("shared"); v != want {
+			t.Fatalf("overwrite=%v: shared = %v, want %v", overwrite, v, want)
+		}
+		if !m.Has("only-mine") || !m.Has("only-theirs") || m.Size() != 4 {
+			t.Fatalf("overwrite=%v: merged map holds %v", overwrite, m.Items())
+		}
+		if _, ok := m.Get("expired"); ok {
+			t.Fatalf("overwrite=%v: an expired key of other came back to life", overwrite)
+		}
+		if _, ok := m.locate("ttl").expires["ttl"]; !ok {
+			t.Fatalf("overwrite=%v: the ttl was not carried over", overwrite)
+		}
+	}
+}
+
+func TestMergeBothWaysWhileResizing(t *testing.T) {
+	a, b := fill(NewWithShard(8), 1000), fill(NewWithShard(8), 1000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parallel(4, func(i int) {
+			for j := 0; j < 50; j++ {
+				switch i {
+				case 0:
+					a.Merge(b, true)
+				case 1:
+					b.Merge(a, false)
+				case 2:
+					a.Resize(8 << (j % 3))
+				case 3:
+					b.Resize(8 << (j % 3))
+				}
+			}
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("concurrent Merge and Resize deadlocked")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := fill(NewWithShard(4), 100)
+	b := fill(NewWithShard(64), 100)
+	a.Set("slice", []int{1, 2})
+	b.Set("slice", []int{1, 2})
+	if !a.Equal(b) || !b.Equal(a) || !a.Equal(a) {
+		t.Fatal("maps with the same entries are not Equal")
+	}
+
+	b.Set("slice", []int{1, 3})
+	if a.Equal(b) {
+		t.Fatal("maps with a different value are Equal")
+	}
+	b.Set("slice", []int{1, 2})
+
+	b.Delete("0")
+	b.Set("extra", 0)
+	if a.Equal(b) || b.Equal(a) {
+		t.Fatal("maps with different keys are Equal")
+	}
+	b.Delete("extra")
+	if a.Equal(b) || a.Equal(nil) {
+		t.Fatal("maps of different sizes are Equal")
+	}
+}
@@ -0,0 +1,60 @@
+package syncmap
+
+// Compact copies every shard into maps sized for its current entries,
+// together with the ttls, recency, sizes and metadata kept per key. Go maps
+// never give back the buckets they grew, so after a burst of inserts
+// followed by deletes this is the only way to return that memory. It copies
+// the whole map, ShrinkIfSparse only rebuilds the shards that shrank.
+func (m *SyncMap) Compact() {
+	var evicted []Item
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		shard.Lock()
+		shard.compactNotLock()
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+}
+
+// ShrinkIfSparse compacts only the shards that fell below ratio times the
+// peak size they reached since their last compaction, so the memory of a
+// shard emptied by deletes is returned without copying the still dense ones.
+func (m *SyncMap) ShrinkIfSparse(ratio float64) {
+	var evicted []Item
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		shard.Lock()
+		if float64(len(shard.items)) < ratio*float64(shard.peak) {
+			shard.compactNotLock()
+		}
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+}
+
+func (sd *ShardMap) compactNotLock() {
+	sd.items = compactMap(sd.items)
+	sd.peak = len(sd.items)
+	sd.expires = compactMap(sd.expires)
+	sd.versions = compactMap(sd.versions)
+	sd.lruIndex = compactMap(sd.lruIndex)
+	sd.sizes = compactMap(sd.sizes)
+	sd.meta = compactMap(sd.meta)
+	sd.orderIndex = compactMap(sd.orderIndex)
+	sd.calls = compactMap(sd.calls)
+}
+
+// compactMap copies src into a map allocated for its current size, a nil
+// map stays nil.
+func compactMap[V any](src map[string]V) map[string]V {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]V, len(src))
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
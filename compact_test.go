@@ -0,0 +1,98 @@
+package syncmap
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func mapPointer(m interface{}) uintptr {
+	return reflect.ValueOf(m).Pointer()
+}
+
+func TestCompactRebuildsShards(t *testing.T) {
+	maps := map[string]*SyncMap{
+		"plain":    NewWithShard(4),
+		"lru":      NewLRU(100000, 4),
+		"maxBytes": NewWithMaxBytes(1<<20, nil),
+		"metadata": NewWithMetadata(),
+		"ordered":  NewOrdered(),
+	}
+	for name, m := range maps {
+		fill(m, 10000)
+		for i := 10; i < 10000; i++ {
+			m.Delete(strconv.Itoa(i))
+		}
+		m.SetWithTTL("ttl", 1, time.Hour)
+		bytes := m.Bytes()
+
+		before := make(map[*ShardMap][]uintptr)
+		for _, shard := range m.GetShards() {
+			before[shard] = []uintptr{mapPointer(shard.items), mapPointer(shard.lruIndex),
+				mapPointer(shard.sizes), mapPointer(shard.meta), mapPointer(shard.orderIndex)}
+		}
+		m.Compact()
+
+		for shard, pointers := range before {
+			after := []uintptr{mapPointer(shard.items), mapPointer(shard.lruIndex),
+				mapPointer(shard.sizes), mapPointer(shard.meta), mapPointer(shard.orderIndex)}
+			for i := range after {
+				if after[i] != 0 && after[i] == pointers[i] {
+					t.Fatalf("%s: Compact kept map %d of a shard", name, i)
+				}
+			}
+			if shard.peak != len(shard.items) {
+				t.Fatalf("%s: peak %d after Compact, %d entries", name, shard.peak, len(shard.items))
+			}
+		}
+		for i := 0; i < 10; i++ {
+			if value, ok := m.Get(strconv.Itoa(i)); !ok || value != i {
+				t.Fatalf("%s: Get(%d) = %v, %v after Compact", name, i, value, ok)
+			}
+		}
+		if _, ttl, ok := m.GetWithTTL("ttl"); !ok || ttl <= 0 {
+			t.Fatalf("%s: Compact dropped a ttl", name)
+		}
+		if m.Bytes() != bytes {
+			t.Fatalf("%s: Bytes() = %d after Compact, want %d", name, m.Bytes(), bytes)
+		}
+		m.Delete("0")
+		if m.Has("0") || m.Size() != 10 {
+			t.Fatalf("%s: Delete after Compact left Size() = %d", name, m.Size())
+		}
+	}
+}
+
+func TestShrinkIfSparse(t *testing.T) {
+	m := fill(NewWithShard(4), 10000)
+	m.SetWithTTL("ttl", 1, time.Hour)
+	for i := 0; i < 9000; i++ {
+		m.Delete(strconv.Itoa(i))
+	}
+	for _, shard := range m.GetShards() {
+		if shard.peak <= len(shard.items) {
+			t.Fatalf("peak %d not above the %d remaining entries", shard.peak, len(shard.items))
+		}
+	}
+
+	m.ShrinkIfSparse(0.05) // the shards kept about 10%, above the ratio
+	for i, shard := range m.GetShards() {
+		if shard.peak == len(shard.items) {
+			t.Fatalf("shard %d was rebuilt while above the ratio", i)
+		}
+	}
+
+	m.ShrinkIfSparse(0.5)
+	for i, shard := range m.GetShards() {
+		if shard.peak != len(shard.items) {
+			t.Fatalf("shard %d was not rebuilt: peak %d, %d entries", i, shard.peak, len(shard.items))
+		}
+	}
+	if m.Size() != 1001 {
+		t.Fatalf("Size() = %d after ShrinkIfSparse, want 1001", m.Size())
+	}
+	if value, ttl, ok := m.GetWithTTL("ttl"); !ok || value != 1 || ttl <= 0 {
+		t.Fatal("ShrinkIfSparse dropped the ttl of an entry")
+	}
+}
@@ -0,0 +1,82 @@
+package syncmap
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrComputePanicked is returned to the callers waiting on a compute that
+// panicked, the panic itself goes to the caller that ran compute.
+var ErrComputePanicked = errors.New("syncmap: compute panicked")
+
+type computeCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// GetOrCompute returns the value of key, calling compute to create it on a
+// miss. Concurrent callers missing the same key wait for the first one's
+// compute instead of running their own. loaded is false only for the caller
+// whose compute result was stored. compute runs without any lock held, when
+// it panics the waiting callers start over as if they had just missed.
+func (m *SyncMap) GetOrCompute(key string, compute func() interface{}) (value interface{}, loaded bool) {
+	for {
+		value, loaded, err := m.getOrCompute(key, func() (interface{}, error) {
+			return compute(), nil
+		})
+		if err != ErrComputePanicked {
+			return value, loaded
+		}
+	}
+}
+
+// GetOrComputeErr is GetOrCompute with a compute that may fail. A failed
+// compute stores nothing, its error is returned to the caller and to those
+// waiting on it, and the next call for key runs compute again. Callers
+// waiting on a compute that panicked get ErrComputePanicked.
+func (m *SyncMap) GetOrComputeErr(key string, compute func() (interface{}, error)) (interface{}, error) {
+	value, _, err := m.getOrCompute(key, compute)
+	return value, err
+}
+
+func (m *SyncMap) getOrCompute(key string, compute func() (interface{}, error)) (value interface{}, loaded bool, err error) {
+	shard := m.lockKey(key)
+	if value, ok := shard.GetNotLock(key); ok {
+		shard.Unlock()
+		return value, true, nil
+	}
+	if call, ok := shard.calls[key]; ok {
+		shard.Unlock()
+		call.wg.Wait()
+		return call.value, call.err == nil, call.err
+	}
+
+	call := new(computeCall)
+	call.wg.Add(1)
+	if shard.calls == nil {
+		shard.calls = make(map[string]*computeCall)
+	}
+	shard.calls[key] = call
+	shard.Unlock()
+
+	returned := false
+	defer func() {
+		if !returned {
+			call.err = ErrComputePanicked
+		}
+		shard.Lock()
+		delete(shard.calls, key)
+		shard.Unlock()
+		call.wg.Done()
+	}()
+
+	value, err = compute()
+	returned = true
+	if err != nil {
+		call.err = err
+		return nil, false, err
+	}
+	call.value, loaded = m.GetOrSet(key, value)
+	return call.value, loaded, nil
+}
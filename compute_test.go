@@ -0,0 +1,98 @@
+package syncmap
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrComputeStampede(t *testing.T) {
+	const keys, callers = 4, 16
+	m := New()
+	var calls [keys]int64
+	var stored int64
+	parallel(keys*callers, func(i int) {
+		k := i % keys
+		value, loaded := m.GetOrCompute(strconv.Itoa(k), func() interface{} {
+			atomic.AddInt64(&calls[k], 1)
+			time.Sleep(10 * time.Millisecond)
+			return k
+		})
+		if value != k {
+			t.Errorf("GetOrCompute(%d) = %v", k, value)
+		}
+		if !loaded {
+			atomic.AddInt64(&stored, 1)
+		}
+	})
+	for k, n := range calls {
+		if n != 1 {
+			t.Fatalf("compute ran %d times for key %d, want once", n, k)
+		}
+	}
+	if stored != keys {
+		t.Fatalf("%d callers reported storing, want %d", stored, keys)
+	}
+}
+
+func TestGetOrComputePanic(t *testing.T) {
+	m := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		m.GetOrCompute("k", func() interface{} {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	results := make(chan interface{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			value, _ := m.GetOrCompute("k", func() interface{} { return "retried" })
+			results <- value
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Fatalf("the computing caller recovered %v, want the compute panic", r)
+	}
+	for i := 0; i < 4; i++ {
+		if value := <-results; value != "retried" {
+			t.Fatalf("a waiter got %v after the compute panicked", value)
+		}
+	}
+}
+
+func TestGetOrComputeErrRetriesAfterError(t *testing.T) {
+	m := New()
+	errBuild := errors.New("build failed")
+	calls := 0
+	build := func() (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errBuild
+		}
+		return "instance", nil
+	}
+
+	if value, err := m.GetOrComputeErr("singleton", build); err != errBuild || value != nil {
+		t.Fatalf("first GetOrComputeErr = %v, %v, want the compute error", value, err)
+	}
+	if m.Has("singleton") {
+		t.Fatal("a failed compute was cached")
+	}
+	if value, err := m.GetOrComputeErr("singleton", build); err != nil || value != "instance" {
+		t.Fatalf("retried GetOrComputeErr = %v, %v", value, err)
+	}
+	if value, err := m.GetOrComputeErr("singleton", build); err != nil || value != "instance" || calls != 2 {
+		t.Fatalf("cached GetOrComputeErr = %v, %v after %d computes", value, err, calls)
+	}
+}
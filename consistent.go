@@ -0,0 +1,38 @@
+package syncmap
+
+// PlacementFunc picks the index in [0, shardCount) of the shard holding a key
+// from the hash of the key.
+type PlacementFunc func(hash uint32, shardCount int) int
+
+// NewConsistent routes keys with jump consistent hashing instead of a mask,
+// so the shard count is kept exactly as given and a Resize from n to m
+// shards moves only about |m-n|/max(m, n) of the keys to another shard
+// index, the others keep their ShardIndex.
+func NewConsistent(shardCount int) *SyncMap {
+	return NewWithPlacement(shardCount, nil, jumpHash)
+}
+
+// NewWithPlacement routes keys with placement instead of the power of two
+// mask, the shard count is kept exactly as given and placement is called
+// with the current one after a Resize. A nil hasher falls back to fnv64.
+func NewWithPlacement(shardCount int, hasher func(string) uint32, placement PlacementFunc) *SyncMap {
+	if hasher == nil {
+		hasher = fnv64
+	}
+	m := new(SyncMap)
+	m.placement = placement
+	m.init(shardCount, hasher)
+	return m
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach.
+func jumpHash(hash uint32, shardCount int) int {
+	key := uint64(hash)
+	b, j := int64(-1), int64(0)
+	for j < int64(shardCount) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,69 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+// movedFraction is the share of keys whose shard index changes when m grows
+// to newShardCount shards.
+func movedFraction(m *SyncMap, keys []string, newShardCount int) float64 {
+	before := make([]int, len(keys))
+	for i, key := range keys {
+		before[i] = m.ShardIndex(key)
+	}
+	m.Resize(newShardCount)
+	moved := 0
+	for i, key := range keys {
+		if m.ShardIndex(key) != before[i] {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(keys))
+}
+
+func TestConsistentResizeMovesFewKeys(t *testing.T) {
+	keys := randomKeys(10000)
+	m := NewConsistent(64)
+	for _, key := range keys {
+		m.Set(key, key)
+	}
+
+	// jump hashing moves the minimum: half of the keys when doubling and a
+	// third from 128 to 192 shards, where a modulo would move two thirds.
+	if moved := movedFraction(m, keys, 128); moved < 0.4 || moved > 0.6 {
+		t.Fatalf("growing from 64 to 128 shards moved %.2f of the keys, want about 0.5", moved)
+	}
+	if moved := movedFraction(m, keys, 192); moved > 0.4 {
+		t.Fatalf("growing from 128 to 192 shards moved %.2f of the keys, want about 0.33", moved)
+	}
+	if m.ShardCount() != 192 || m.Size() != len(keys) {
+		t.Fatalf("%d shards and %d entries after the resizes", m.ShardCount(), m.Size())
+	}
+	for _, key := range keys[:100] {
+		if value, _ := m.Get(key); value != key {
+			t.Fatalf("Get(%s) = %v after Resize", key, value)
+		}
+	}
+}
+
+func TestWithPlacement(t *testing.T) {
+	modulo := func(hash uint32, shardCount int) int {
+		return int(hash % uint32(shardCount))
+	}
+	m := NewWithPlacement(3, nil, modulo)
+	fill(m, 300)
+	if m.ShardCount() != 3 {
+		t.Fatalf("ShardCount() = %d, want 3", m.ShardCount())
+	}
+	m.Resize(5)
+	for i := 0; i < 300; i++ {
+		key := strconv.Itoa(i)
+		if want := modulo(fnv64(key), 5); m.ShardIndex(key) != want {
+			t.Fatalf("ShardIndex(%s) = %d, want %d", key, m.ShardIndex(key), want)
+		}
+		if value, _ := m.Get(key); value != i {
+			t.Fatalf("Get(%s) = %v after Resize", key, value)
+		}
+	}
+}
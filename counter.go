@@ -0,0 +1,48 @@
+package syncmap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CounterMap holds int64 counters whose Add is a single atomic add once the
+// key exists. The shard write lock is taken once per key to insert its
+// counter, which is then cached in a sync.Map in front of the shards, so
+// later adds take no lock at all.
+type CounterMap struct {
+	m        *SyncMap
+	counters sync.Map
+}
+
+func NewCounterMap(shardCount int) *CounterMap {
+	return &CounterMap{m: NewWithShard(shardCount)}
+}
+
+func (c *CounterMap) counter(key string) *int64 {
+	if v, ok := c.counters.Load(key); ok {
+		return v.(*int64)
+	}
+	v, _ := c.m.GetOrSet(key, new(int64))
+	c.counters.Store(key, v)
+	return v.(*int64)
+}
+
+// Add increments the counter of key by delta and returns the new total, a
+// missing key starts at zero.
+func (c *CounterMap) Add(key string, delta int64) int64 {
+	return atomic.AddInt64(c.counter(key), delta)
+}
+
+// CounterValue returns the total of key, ok is false when Add was never
+// called for it.
+func (c *CounterMap) CounterValue(key string) (int64, bool) {
+	v, ok := c.counters.Load(key)
+	if !ok {
+		return 0, false
+	}
+	return atomic.LoadInt64(v.(*int64)), true
+}
+
+func (c *CounterMap) Size() int {
+	return c.m.Size()
+}
@@ -0,0 +1,72 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCounterMapConcurrentAdd(t *testing.T) {
+	c := NewCounterMap(4)
+	parallel(8, func(int) {
+		for i := 0; i < 1000; i++ {
+			c.Add(strconv.Itoa(i%10), 1)
+		}
+	})
+	for i := 0; i < 10; i++ {
+		if n, ok := c.CounterValue(strconv.Itoa(i)); !ok || n != 800 {
+			t.Fatalf("CounterValue(%d) = %d, %v, want 800", i, n, ok)
+		}
+	}
+	if _, ok := c.CounterValue("missing"); ok || c.Size() != 10 {
+		t.Fatal("CounterValue reported a missing counter")
+	}
+}
+
+// hotKeys all live in the same shard of a 128 shard map, so the adds of the
+// benchmark contend on a single shard lock.
+func hotKeys(m *SyncMap, n int) []string {
+	var keys []string
+	for i := 0; len(keys) < n; i++ {
+		key := strconv.Itoa(i)
+		if m.ShardIndex(key) == 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func BenchmarkCounterAdd(b *testing.B) {
+	b.Run("SyncMap.Add", func(b *testing.B) {
+		m := New()
+		keys := hotKeys(m, 16)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				m.Add(keys[i%len(keys)], 1)
+			}
+		})
+	})
+	b.Run("CounterMap.Add", func(b *testing.B) {
+		c := NewCounterMap(defaultShardCount)
+		keys := hotKeys(c.m, 16)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Add(keys[i%len(keys)], 1)
+			}
+		})
+	})
+}
+
+func TestCounterMapAddTakesNoLock(t *testing.T) {
+	c := NewCounterMap(1)
+	c.Add("k", 1)
+	shard := c.m.Locate("k")
+	shard.Lock()
+	c.Add("k", 2)
+	total, _ := c.CounterValue("k")
+	shard.RWMutex.Unlock()
+	if total != 3 {
+		t.Fatalf("CounterValue(k) = %d, want 3", total)
+	}
+}
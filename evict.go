@@ -0,0 +1,89 @@
+package syncmap
+
+import (
+	"sync/atomic"
+)
+
+type EvictFunc func(key string, value interface{})
+
+// shardHooks is shared by all shards of a map.
+type shardHooks struct {
+	onEvicted atomic.Pointer[EvictFunc]
+	watch     *watchers
+	// version counts the mutations of the map, see Version.
+	version atomic.Uint64
+	// budget is shared by the shards of a map built by NewWithMaxBytes.
+	budget *byteBudget
+}
+
+func (h *shardHooks) mutated() {
+	if h != nil {
+		h.version.Add(1)
+	}
+}
+
+func (h *shardHooks) evicting() bool {
+	return h != nil && h.onEvicted.Load() != nil
+}
+
+// OnEvicted registers fn to be called for entries the map drops: Delete,
+// MDelete, ttl expiry and Flush. Values handed back to the caller, as by Pop
+// or GetAndDelete, are not reported. fn runs after the shard lock is
+// released, in removal order for a given shard but with no ordering across
+// shards, so it may touch the map. Passing nil unregisters the callback.
+func (m *SyncMap) OnEvicted(fn EvictFunc) {
+	if fn == nil {
+		m.hooks.onEvicted.Store(nil)
+		return
+	}
+	m.hooks.onEvicted.Store(&fn)
+}
+
+func (sd *ShardMap) evictNotLock(key string, value interface{}) {
+	if sd.hooks.evicting() {
+		sd.evicted = append(sd.evicted, Item{key, value})
+	}
+}
+
+// Unlock publishes the read view if the shard has one, releases the write
+// lock and then reports the entries evicted while it was held.
+func (sd *ShardMap) Unlock() {
+	if sd.view != nil {
+		sd.view.publish(sd)
+	}
+	evicted := sd.evicted
+	sd.evicted = nil
+	sd.RWMutex.Unlock()
+	sd.hooks.notify(evicted)
+}
+
+// unlockDeferred releases the write lock but appends the evicted entries to
+// evicted instead of reporting them, for scans that still hold m.mu and must
+// not run callbacks that could call back into the map.
+func (sd *ShardMap) unlockDeferred(evicted []Item) []Item {
+	if sd.view != nil {
+		sd.view.publish(sd)
+	}
+	evicted = append(evicted, sd.evicted...)
+	sd.evicted = nil
+	sd.RWMutex.Unlock()
+	return evicted
+}
+
+// notify reports evicted to the OnEvicted callback. It is called without any
+// lock held, so it first brings a map over its byte budget back under it.
+func (h *shardHooks) notify(evicted []Item) {
+	if h.budget != nil {
+		evicted = append(evicted, h.budget.enforce()...)
+	}
+	if len(evicted) == 0 {
+		return
+	}
+	fn := h.onEvicted.Load()
+	if fn == nil {
+		return
+	}
+	for _, item := range evicted {
+		(*fn)(item.Key, item.Value)
+	}
+}
@@ -0,0 +1,64 @@
+package syncmap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnEvictedDeleteAndFlush(t *testing.T) {
+	m := fill(New(), 10)
+	var (
+		mu      sync.Mutex
+		evicted = make(map[string]int)
+	)
+	m.OnEvicted(func(key string, value interface{}) {
+		mu.Lock()
+		evicted[key]++
+		mu.Unlock()
+		m.Has(key) // the shard lock is released, calling back must not deadlock
+	})
+
+	m.Delete("1")
+	m.Delete("missing")
+	m.GetAndDelete("2")
+	if n := m.Flush(); n != 8 {
+		t.Fatalf("Flush() = %d, want 8", n)
+	}
+
+	if len(evicted) != 9 {
+		t.Fatalf("%d keys reported evicted, want 9 (Delete plus Flush): %v", len(evicted), evicted)
+	}
+	for key, n := range evicted {
+		if n != 1 {
+			t.Fatalf("key %s reported %d times", key, n)
+		}
+	}
+	if evicted["2"] != 0 {
+		t.Fatal("GetAndDelete hands the value back and must not report it")
+	}
+
+	m.OnEvicted(nil)
+	m.Set("x", 1)
+	m.Delete("x")
+	if len(evicted) != 9 {
+		t.Fatal("callback still called after unregistering")
+	}
+}
+
+func TestOnEvictedExpiredPop(t *testing.T) {
+	m := NewWithShard(1)
+	m.SetWithTTL("expired", 1, time.Nanosecond)
+	m.Set("live", 2)
+	var evicted []string
+	m.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	time.Sleep(time.Millisecond)
+
+	for _, _, ok := m.Pop(); ok; _, _, ok = m.Pop() {
+	}
+	if len(evicted) != 1 || evicted[0] != "expired" {
+		t.Fatalf("Pop reported %v as evicted, want the expired key", evicted)
+	}
+}
@@ -0,0 +1,74 @@
+package syncmap
+
+type PredicateFunc func(key string, value interface{}) bool
+
+// CountFunc returns how many entries satisfy pred, pred runs under the shard
+// read lock and must not mutate the map.
+func (m *SyncMap) CountFunc(pred PredicateFunc) int {
+	count := 0
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key, value := range shard.items {
+			if pred(key, value) {
+				count++
+			}
+		}
+		shard.RUnlock()
+	}
+	return count
+}
+
+// Filter returns a new map built like Clone holding the live entries that
+// satisfy pred, with their ttl. Values are shared by reference. pred runs
+// under the shard read lock and must not mutate the map.
+func (m *SyncMap) Filter(pred PredicateFunc) *SyncMap {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filtered := m.emptyLike()
+	filtered.fillNotLock(m.shards, true, pred)
+	return filtered
+}
+
+// Reduce folds every entry into an accumulator starting from initial. fn runs
+// under the shard read lock and must not call back into the map.
+func (m *SyncMap) Reduce(initial interface{}, fn func(acc interface{}, item *Item) interface{}) interface{} {
+	acc := initial
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key, value := range shard.items {
+			acc = fn(acc, &Item{key, value})
+		}
+		shard.RUnlock()
+	}
+	return acc
+}
+
+// DeleteFunc removes every entry that satisfies pred and returns how many it
+// removed. pred runs under the shard write lock and must not call back into
+// the map.
+func (m *SyncMap) DeleteFunc(pred PredicateFunc) int {
+	var (
+		removed int
+		evicted []Item
+		keys    []string
+	)
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		keys = keys[:0]
+		shard.Lock()
+		for key, value := range shard.items {
+			if pred(key, value) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			shard.DeleteNotLock(key)
+		}
+		removed += len(keys)
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+	return removed
+}
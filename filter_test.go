@@ -0,0 +1,73 @@
+package syncmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountFunc(t *testing.T) {
+	m := fill(New(), 100)
+	n := m.CountFunc(func(key string, value interface{}) bool {
+		return value.(int) >= 90
+	})
+	if n != 10 {
+		t.Fatalf("CountFunc counted %d values >= 90, want 10", n)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	m := fill(New(), 100)
+	m.SetWithTTL("ttl", 1000, time.Hour)
+	m.SetWithTTL("expired", 2000, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	none := m.Filter(func(string, interface{}) bool { return false })
+	if none.Size() != 0 || none.ShardCount() != m.ShardCount() {
+		t.Fatal("Filter rejecting everything returned entries")
+	}
+
+	large := m.Filter(func(key string, value interface{}) bool { return value.(int) >= 50 })
+	if large.Size() != 51 {
+		t.Fatalf("filtered map holds %d entries, want 51", large.Size())
+	}
+	if large.Has("49") || !large.Has("50") || m.Size() != 102 {
+		t.Fatal("Filter picked the wrong entries or changed the source")
+	}
+	if _, ok := large.Get("expired"); ok {
+		t.Fatal("Filter brought an expired entry back")
+	}
+	if _, ok := large.locate("ttl").expires["ttl"]; !ok {
+		t.Fatal("Filter dropped the ttl")
+	}
+}
+
+func TestReduceSum(t *testing.T) {
+	m := fill(New(), 1001)
+	sum := m.Reduce(0, func(acc interface{}, item *Item) interface{} {
+		return acc.(int) + item.Value.(int)
+	})
+	if sum != 1000*1001/2 {
+		t.Fatalf("Reduce sum = %v, want %d", sum, 1000*1001/2)
+	}
+	if got := New().Reduce("initial", nil); got != "initial" {
+		t.Fatalf("Reduce over an empty map = %v, want the initial value", got)
+	}
+}
+
+func TestDeleteFuncKeepsSurvivors(t *testing.T) {
+	m := fill(New(), 1000)
+	n := m.DeleteFunc(func(key string, value interface{}) bool {
+		return value.(int)%3 == 0
+	})
+	if n != 334 || m.Size() != 666 {
+		t.Fatalf("DeleteFunc removed %d entries and left %d, want 334 and 666", n, m.Size())
+	}
+	m.EachItem(func(item *Item) {
+		if item.Value.(int)%3 == 0 {
+			t.Fatalf("%s survived DeleteFunc", item.Key)
+		}
+	})
+	if !m.Has("1") || !m.Has("998") || m.Has("999") {
+		t.Fatal("DeleteFunc removed the wrong entries")
+	}
+}
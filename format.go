@@ -0,0 +1,30 @@
+package syncmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stringMaxItems caps how many entries String prints.
+const stringMaxItems = 100
+
+// String lists the entries sorted by key as {k1=v1, k2=v2}, maps larger than
+// stringMaxItems end with "... (N more)".
+func (m *SyncMap) String() string {
+	items := m.sortedItems()
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, item := range items {
+		if i == stringMaxItems {
+			fmt.Fprintf(&b, ", ... (%d more)", len(items)-stringMaxItems)
+			break
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s=%v", item.Key, item.Value)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
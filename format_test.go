@@ -0,0 +1,39 @@
+package syncmap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	m := New()
+	if s := m.String(); s != "{}" {
+		t.Fatalf("String() of an empty map = %q", s)
+	}
+
+	m.Set("b", 2)
+	m.Set("a", "x")
+	m.Set("c", []int{1})
+	if s := m.String(); s != "{a=x, b=2, c=[1]}" {
+		t.Fatalf("String() = %q", s)
+	}
+}
+
+func TestStringTruncates(t *testing.T) {
+	m := New()
+	for i := 0; i < stringMaxItems+5; i++ {
+		m.Set(fmt.Sprintf("%04d", i), i)
+	}
+
+	s := m.String()
+	if !strings.HasPrefix(s, "{0000=0, 0001=1, ") || !strings.HasSuffix(s, ", 0099=99, ... (5 more)}") {
+		t.Fatalf("String() = %q", s)
+	}
+	if n := strings.Count(s, "="); n != stringMaxItems {
+		t.Fatalf("String() printed %d entries, want %d", n, stringMaxItems)
+	}
+	if s != m.String() {
+		t.Fatal("String() is not stable")
+	}
+}
@@ -0,0 +1,204 @@
+package syncmap
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+var genericSeed = maphash.MakeSeed()
+
+type mapShard[K comparable, V any] struct {
+	items map[K]V
+	sync.RWMutex
+}
+
+// Map is the type-safe counterpart of SyncMap, values are stored without
+// boxing them into interface{}.
+type Map[K comparable, V any] struct {
+	shardCount int
+	shards     []*mapShard[K, V]
+	hasher     func(K) uint32
+}
+
+type GenericItem[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+func NewMap[K comparable, V any]() *Map[K, V] {
+	return NewMapWithShard[K, V](defaultShardCount)
+}
+
+func NewMapWithShard[K comparable, V any](shardCount int) *Map[K, V] {
+	return NewMapWithHasher[K, V](shardCount, DefaultHasher[K]())
+}
+
+func NewMapWithHasher[K comparable, V any](shardCount int, hasher func(K) uint32) *Map[K, V] {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	shardCount = NextPow2(shardCount)
+	if hasher == nil {
+		hasher = DefaultHasher[K]()
+	}
+
+	m := &Map[K, V]{
+		shardCount: shardCount,
+		shards:     make([]*mapShard[K, V], shardCount),
+		hasher:     hasher,
+	}
+	for i := range m.shards {
+		m.shards[i] = &mapShard[K, V]{items: make(map[K]V)}
+	}
+	return m
+}
+
+// DefaultHasher returns fnv32 for string keys, a mixed hash for the integer
+// types and a maphash based hash for any other comparable key.
+func DefaultHasher[K comparable]() func(K) uint32 {
+	var zero K
+	switch any(zero).(type) {
+	case string:
+		return func(key K) uint32 { return fnv32(any(key).(string)) }
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return func(key K) uint32 { return hashUint64(toUint64(key)) }
+	default:
+		return func(key K) uint32 { return uint32(maphash.Comparable(genericSeed, key)) }
+	}
+}
+
+func toUint64[K comparable](key K) uint64 {
+	switch v := any(key).(type) {
+	case int:
+		return uint64(v)
+	case int8:
+		return uint64(v)
+	case int16:
+		return uint64(v)
+	case int32:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case uintptr:
+		return uint64(v)
+	}
+	return 0
+}
+
+// hashUint64 is the splitmix64 finalizer folded to 32 bits, sequential
+// integers would otherwise all land in neighbouring shards.
+func hashUint64(x uint64) uint32 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return uint32(x ^ (x >> 32))
+}
+
+func (m *Map[K, V]) ShardCount() int {
+	return m.shardCount
+}
+
+func (m *Map[K, V]) locate(key K) *mapShard[K, V] {
+	return m.shards[m.hasher(key)&uint32((m.shardCount-1))]
+}
+
+func (m *Map[K, V]) Get(key K) (value V, ok bool) {
+	shard := m.locate(key)
+	shard.RLock()
+	value, ok = shard.items[key]
+	shard.RUnlock()
+	return value, ok
+}
+
+func (m *Map[K, V]) Set(key K, value V) {
+	shard := m.locate(key)
+	shard.Lock()
+	shard.items[key] = value
+	shard.Unlock()
+}
+
+func (m *Map[K, V]) Delete(key K) {
+	shard := m.locate(key)
+	shard.Lock()
+	delete(shard.items, key)
+	shard.Unlock()
+}
+
+func (m *Map[K, V]) Has(key K) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+func (m *Map[K, V]) Size() int {
+	size := 0
+	for _, shard := range m.shards {
+		shard.RLock()
+		size += len(shard.items)
+		shard.RUnlock()
+	}
+	return size
+}
+
+func (m *Map[K, V]) EachKeyWithBreak(iter func(key K) bool) {
+	stop := false
+	for _, shard := range m.shards {
+		shard.RLock()
+		for key := range shard.items {
+			if !iter(key) {
+				stop = true
+				break
+			}
+		}
+		shard.RUnlock()
+		if stop {
+			break
+		}
+	}
+}
+
+func (m *Map[K, V]) EachItemWithBreak(iter func(item *GenericItem[K, V]) bool) {
+	stop := false
+	for _, shard := range m.shards {
+		shard.RLock()
+		for key, value := range shard.items {
+			if !iter(&GenericItem[K, V]{key, value}) {
+				stop = true
+				break
+			}
+		}
+		shard.RUnlock()
+		if stop {
+			break
+		}
+	}
+}
+
+func (m *Map[K, V]) EachItem(iter func(item *GenericItem[K, V])) {
+	m.EachItemWithBreak(func(item *GenericItem[K, V]) bool {
+		iter(item)
+		return true
+	})
+}
+
+func (m *Map[K, V]) IterItems() <-chan GenericItem[K, V] {
+	ch := make(chan GenericItem[K, V])
+	go func() {
+		m.EachItem(func(item *GenericItem[K, V]) {
+			ch <- *item
+		})
+		close(ch)
+	}()
+	return ch
+}
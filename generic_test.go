@@ -0,0 +1,112 @@
+package syncmap
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+type pointKey struct {
+	X, Y int
+	Name string
+}
+
+// checkMap runs the basic operations of Map on the n keys key(0..n-1).
+func checkMap[K comparable](t *testing.T, key func(i int) K, n int) {
+	t.Helper()
+	m := NewMapWithShard[K, int](16)
+	for i := 0; i < n; i++ {
+		m.Set(key(i), i)
+	}
+	if m.Size() != n {
+		t.Fatalf("Size() = %d, want %d", m.Size(), n)
+	}
+	for i := 0; i < n; i++ {
+		if value, ok := m.Get(key(i)); !ok || value != i {
+			t.Fatalf("Get(%v) = %v, %v, want %d", key(i), value, ok, i)
+		}
+	}
+
+	sum := 0
+	m.EachItem(func(item *GenericItem[K, int]) {
+		if item.Key != key(item.Value) {
+			t.Fatalf("EachItem paired %v with %d", item.Key, item.Value)
+		}
+		sum += item.Value
+	})
+	if want := n * (n - 1) / 2; sum != want {
+		t.Fatalf("EachItem summed %d, want %d", sum, want)
+	}
+	visited := 0
+	m.EachItemWithBreak(func(*GenericItem[K, int]) bool {
+		visited++
+		return visited < 3
+	})
+	keys := 0
+	m.EachKeyWithBreak(func(K) bool {
+		keys++
+		return keys < 3
+	})
+	if visited != 3 || keys != 3 {
+		t.Fatalf("EachItemWithBreak visited %d, EachKeyWithBreak %d, want 3", visited, keys)
+	}
+	streamed := 0
+	for range m.IterItems() {
+		streamed++
+	}
+	if streamed != n {
+		t.Fatalf("IterItems sent %d items, want %d", streamed, n)
+	}
+
+	for i := 0; i < n; i += 2 {
+		m.Delete(key(i))
+	}
+	if m.Size() != n/2 || m.Has(key(0)) || !m.Has(key(1)) {
+		t.Fatalf("Size() = %d after deleting every other key", m.Size())
+	}
+}
+
+func TestMapStringKeys(t *testing.T) {
+	checkMap(t, strconv.Itoa, 1000)
+}
+
+func TestMapIntKeys(t *testing.T) {
+	checkMap(t, func(i int) int { return i }, 1000)
+	checkMap(t, func(i int) uint8 { return uint8(i) }, 200)
+}
+
+func TestMapStructKeys(t *testing.T) {
+	checkMap(t, func(i int) pointKey { return pointKey{i, -i, strconv.Itoa(i % 7)} }, 1000)
+}
+
+// shardLoads counts the keys of m per shard, in ascending order.
+func shardLoads[K comparable, V any](m *Map[K, V]) []int {
+	index := make(map[*mapShard[K, V]]int, len(m.shards))
+	for i, shard := range m.shards {
+		index[shard] = i
+	}
+	loads := make([]int, len(m.shards))
+	m.EachKeyWithBreak(func(key K) bool {
+		loads[index[m.locate(key)]]++
+		return true
+	})
+	sort.Ints(loads)
+	return loads
+}
+
+func TestDefaultHasherSpread(t *testing.T) {
+	const shards, perShard = 64, 1000
+	ints := NewMapWithShard[int, struct{}](shards)
+	structs := NewMapWithShard[pointKey, struct{}](shards)
+	for i := 0; i < shards*perShard; i++ {
+		ints.Set(i, struct{}{})
+		structs.Set(pointKey{X: i}, struct{}{})
+	}
+	for name, loads := range map[string][]int{"int": shardLoads(ints), "struct": shardLoads(structs)} {
+		// a random spread stays within a few standard deviations, about 32
+		// keys here, sequential keys behind a weak hash would not.
+		if min, max := loads[0], loads[len(loads)-1]; min < perShard*8/10 || max > perShard*12/10 {
+			t.Fatalf("%s keys spread from %d to %d per shard, want about %d", name, min, max, perShard)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package syncmap
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// GobEncode encodes a snapshot of the entries. Values travel as interface{},
+// so their concrete types must be registered with gob.Register by the caller.
+func (m *SyncMap) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m.Items()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode adds the decoded entries to the map, a zero SyncMap is
+// initialized with the default shard count.
+func (m *SyncMap) GobDecode(data []byte) error {
+	var items map[string]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return err
+	}
+	if m.shards == nil {
+		m.init(defaultShardCount, nil)
+	}
+	m.MSet(items)
+	return nil
+}
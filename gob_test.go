@@ -0,0 +1,34 @@
+package syncmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+	"testing"
+)
+
+func TestGobRoundTrip(t *testing.T) {
+	m := fill(NewWithShard(16), 1000)
+	m.Set("str", "value")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewWithShard(4)
+	if err := gob.NewDecoder(&buf).Decode(restored); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.ShardCount() != 4 || restored.Size() != m.Size() {
+		t.Fatalf("restored %d entries into %d shards, want %d into 4", restored.Size(), restored.ShardCount(), m.Size())
+	}
+	for i := 0; i < 1000; i++ {
+		if value, ok := restored.Get(strconv.Itoa(i)); !ok || value != i {
+			t.Fatalf("restored %d = %v, %v", i, value, ok)
+		}
+	}
+	if value, _ := restored.Get("str"); value != "value" {
+		t.Fatalf("restored str = %v", value)
+	}
+}
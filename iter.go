@@ -0,0 +1,302 @@
+package syncmap
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ctxCheckInterval is how many entries are visited between two checks of
+// the context inside a shard.
+const ctxCheckInterval = 1024
+
+// EachItemParallel hands whole shards to a pool of workers, each worker holds
+// the read lock of its shard while calling fn, so fn must be safe for
+// concurrent use and must not call back into the map. workers is capped at
+// the shard count.
+func (m *SyncMap) EachItemParallel(workers int, fn IterItemFunc) {
+	all := m.GetShards()
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(all) {
+		workers = len(all)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		shards = make(chan *ShardMap, len(all))
+	)
+	for _, shard := range all {
+		shards <- shard
+	}
+	close(shards)
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for shard := range shards {
+				shard.RLock()
+				for key, value := range shard.items {
+					fn(&Item{key, value})
+				}
+				shard.RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// EachItemCtx is EachItemWithBreak that gives up once ctx is done, the
+// context is checked between shards and every ctxCheckInterval entries. It
+// returns ctx.Err() when cancelled and nil otherwise, including when fn stops
+// the iteration.
+func (m *SyncMap) EachItemCtx(ctx context.Context, fn IterItemWithBreakFunc) error {
+	for _, shard := range m.GetShards() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var (
+			err     error
+			stop    bool
+			visited int
+		)
+		shard.RLock()
+		for key, value := range shard.items {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err = ctx.Err(); err != nil {
+					break
+				}
+			}
+			if !fn(&Item{key, value}) {
+				stop = true
+				break
+			}
+		}
+		shard.RUnlock()
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
+// IterItemsCtx is IterItems whose producer stops and closes the channel once
+// ctx is done, so consumers may stop reading early without leaking it.
+func (m *SyncMap) IterItemsCtx(ctx context.Context) <-chan Item {
+	ch := make(chan Item)
+	go func() {
+		defer close(ch)
+		m.EachItemWithBreak(func(item *Item) bool {
+			select {
+			case ch <- *item:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return ch
+}
+
+// SnapshotEach copies each shard under a short read lock and calls fn on the
+// copy after releasing it, so fn may block or mutate the map at the cost of
+// holding one shard worth of items in memory.
+func (m *SyncMap) SnapshotEach(fn IterItemFunc) {
+	for _, shard := range m.GetShards() {
+		items := shard.snapshot()
+		for i := range items {
+			fn(&items[i])
+		}
+	}
+}
+
+// EachItemErr is SnapshotEach stopping at the first error returned by fn and
+// returning it, fn runs without any lock held and may do I/O.
+func (m *SyncMap) EachItemErr(fn func(item *Item) error) error {
+	for _, shard := range m.GetShards() {
+		items := shard.snapshot()
+		for i := range items {
+			if err := fn(&items[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sd *ShardMap) snapshot() []Item {
+	sd.RLock()
+	items := make([]Item, 0, len(sd.items))
+	for key, value := range sd.items {
+		items = append(items, Item{key, value})
+	}
+	sd.RUnlock()
+	return items
+}
+
+// EachItemReuse is EachItemWithBreak without the per entry allocation, the
+// same Item is refilled for every entry so the pointer must not be retained
+// after fn returns.
+func (m *SyncMap) EachItemReuse(fn IterItemWithBreakFunc) {
+	var item Item
+	for _, shard := range m.GetShards() {
+		stop := false
+		shard.RLock()
+		for item.Key, item.Value = range shard.items {
+			if !fn(&item) {
+				stop = true
+				break
+			}
+		}
+		shard.RUnlock()
+		if stop {
+			return
+		}
+	}
+}
+
+// EachItemSorted calls fn for a snapshot of the entries in lexicographic key
+// order, no lock is held while fn runs.
+func (m *SyncMap) EachItemSorted(fn IterItemFunc) {
+	items := m.sortedItems()
+	for i := range items {
+		fn(&items[i])
+	}
+}
+
+func (m *SyncMap) sortedItems() []Item {
+	items := make([]Item, 0, m.Size())
+	for _, shard := range m.GetShards() {
+		items = append(items, shard.snapshot()...)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	return items
+}
+
+// Drain removes the entries shard by shard and streams them on the returned
+// channel, each shard is emptied under its write lock and fed to the channel
+// after the lock is released. Expired entries are reported to OnEvicted
+// instead of being sent. Once ctx is done the producer stops, puts the
+// undelivered entries of the current shard back with their ttl, version and
+// metadata unless the key was set again meanwhile, and closes the channel.
+// Entries written concurrently to an already drained shard are left in the
+// map.
+func (m *SyncMap) Drain(ctx context.Context) <-chan Item {
+	ch := make(chan Item)
+	go func() {
+		defer close(ch)
+		for i := 0; ; i++ {
+			entries, ok := m.drainShard(i)
+			if !ok {
+				return
+			}
+			for j := range entries {
+				select {
+				case ch <- entries[j].item:
+				case <-ctx.Done():
+					m.putBack(entries[j:])
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// drained is an entry removed by Drain with the state it is put back with.
+type drained struct {
+	item      Item
+	deadline  int64
+	version   uint64
+	versioned bool
+	meta      *entryMeta
+}
+
+func (m *SyncMap) drainShard(index int) ([]drained, bool) {
+	m.mu.RLock()
+	if index >= m.shardCount {
+		m.mu.RUnlock()
+		return nil, false
+	}
+	shard := m.shards[index]
+	shard.Lock()
+	entries := make([]drained, 0, len(shard.items))
+	now := time.Now().UnixNano()
+	for key, value := range shard.items {
+		if shard.expiredNotLock(key, now) {
+			shard.DeleteNotLock(key)
+			continue
+		}
+		version, versioned := shard.versions[key]
+		entries = append(entries, drained{Item{key, value}, shard.expires[key], version, versioned, shard.meta[key]})
+		shard.takeNotLock(key)
+	}
+	evicted := shard.unlockDeferred(nil)
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+	return entries, true
+}
+
+// putBack re-inserts entries removed by Drain whose key is still missing.
+func (m *SyncMap) putBack(entries []drained) {
+	for _, entry := range entries {
+		key := entry.item.Key
+		shard := m.lockKey(key)
+		if _, ok := shard.GetNotLock(key); !ok {
+			shard.SetNotLock(key, entry.item.Value)
+			if _, kept := shard.items[key]; kept {
+				shard.setDeadlineNotLock(key, entry.deadline)
+				if entry.versioned {
+					shard.setVersionNotLock(key, entry.version)
+				}
+				if entry.meta != nil && shard.meta != nil {
+					shard.meta[key] = entry.meta
+				}
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+// OpKeep is the zero Op, an EachItemMutable callback returns it to leave the
+// entry as it is.
+const OpKeep Op = 0
+
+// EachItemMutable calls fn for every entry under the shard write lock, fn
+// returns OpSet with the value to store, OpDelete to remove the entry or
+// OpKeep. The requested changes of a shard are applied once its whole range
+// is done, fn must not call back into the map.
+func (m *SyncMap) EachItemMutable(fn func(item *Item) (op Op, newValue interface{})) {
+	var (
+		evicted []Item
+		changes []ChangeEvent
+	)
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		changes = changes[:0]
+		shard.Lock()
+		for key, value := range shard.items {
+			if op, newValue := fn(&Item{key, value}); op != OpKeep {
+				changes = append(changes, ChangeEvent{Key: key, Op: op, Value: newValue})
+			}
+		}
+		for _, change := range changes {
+			switch change.Op {
+			case OpSet:
+				shard.SetNotLock(change.Key, change.Value)
+			case OpDelete:
+				shard.DeleteNotLock(change.Key)
+			}
+		}
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+}
@@ -0,0 +1,270 @@
+package syncmap
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEachItemParallelSum(t *testing.T) {
+	m := fill(New(), 10000)
+	var serial int64
+	m.EachItem(func(item *Item) {
+		serial += int64(item.Value.(int))
+	})
+
+	for _, workers := range []int{0, 1, 8, 1000} {
+		var sum atomic.Int64
+		m.EachItemParallel(workers, func(item *Item) {
+			sum.Add(int64(item.Value.(int)))
+		})
+		if sum.Load() != serial {
+			t.Fatalf("workers=%d: parallel sum %d, serial sum %d", workers, sum.Load(), serial)
+		}
+	}
+}
+
+func TestEachItemCtxCancel(t *testing.T) {
+	m := fill(New(), 10000)
+	ctx, cancel := context.WithCancel(context.Background())
+	visited := 0
+	err := m.EachItemCtx(ctx, func(item *Item) bool {
+		visited++
+		if visited == 100 {
+			cancel()
+		}
+		return true
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("EachItemCtx returned %v, want context.Canceled", err)
+	}
+	if visited >= m.Size() {
+		t.Fatal("EachItemCtx visited every entry despite the cancellation")
+	}
+
+	visited = 0
+	err = m.EachItemCtx(context.Background(), func(item *Item) bool {
+		visited++
+		return visited < 10
+	})
+	if err != nil || visited != 10 {
+		t.Fatalf("stopping from fn gave %v after %d entries", err, visited)
+	}
+}
+
+func TestIterItemsCtxProducerExits(t *testing.T) {
+	m := fill(New(), 10000)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := m.IterItemsCtx(ctx)
+		<-ch
+		<-ch
+		cancel() // ch is abandoned without draining it
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running, %d before the iterations", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := 0
+	for range m.IterItemsCtx(ctx) {
+		n++
+	}
+	if n > 1 {
+		t.Fatalf("a cancelled context still produced %d items", n)
+	}
+}
+
+func TestSnapshotEachAllowsWrites(t *testing.T) {
+	m := fill(New(), 1000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.SnapshotEach(func(item *Item) {
+			if item.Value.(int)%2 == 0 {
+				m.Delete(item.Key)
+			} else {
+				m.Set(item.Key, item.Value.(int)*10)
+			}
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("writing from the SnapshotEach callback deadlocked")
+	}
+	if m.Size() != 500 {
+		t.Fatalf("Size() = %d, want 500", m.Size())
+	}
+	if v, _ := m.Get("3"); v != 30 {
+		t.Fatalf("Get(3) = %v, want 30", v)
+	}
+}
+
+func BenchmarkEachItem(b *testing.B) {
+	m := fill(New(), 1000000)
+	var sum int
+	b.Run("EachItemWithBreak", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.EachItemWithBreak(func(item *Item) bool {
+				sum += item.Value.(int)
+				return true
+			})
+		}
+	})
+	b.Run("EachItemReuse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.EachItemReuse(func(item *Item) bool {
+				sum += item.Value.(int)
+				return true
+			})
+		}
+	})
+}
+
+func TestEachItemSorted(t *testing.T) {
+	m := New()
+	for _, key := range randomKeys(1000) {
+		m.Set(key, key)
+	}
+
+	var prev string
+	visited := 0
+	m.EachItemSorted(func(item *Item) {
+		if visited > 0 && item.Key <= prev {
+			t.Fatalf("EachItemSorted visited %q after %q", item.Key, prev)
+		}
+		if item.Value != item.Key {
+			t.Fatalf("EachItemSorted passed %v for %q", item.Value, item.Key)
+		}
+		prev = item.Key
+		visited++
+	})
+	if visited != m.Size() {
+		t.Fatalf("EachItemSorted visited %d of %d entries", visited, m.Size())
+	}
+}
+
+func TestDrain(t *testing.T) {
+	m := fill(New(), 1000)
+	seen := make(map[string]bool)
+	for item := range m.Drain(context.Background()) {
+		if seen[item.Key] {
+			t.Fatalf("Drain sent %s twice", item.Key)
+		}
+		seen[item.Key] = true
+	}
+	if len(seen) != 1000 || !m.IsEmpty() {
+		t.Fatalf("Drain sent %d entries and left %d, want 1000 and none", len(seen), m.Size())
+	}
+}
+
+func TestDrainCancelPutsBack(t *testing.T) {
+	m := NewWithShard(1)
+	for i := 0; i < 100; i++ {
+		m.SetWithTTL(strconv.Itoa(i), i, time.Hour)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := m.Drain(ctx)
+	received := 0
+	for ; received < 10; received++ {
+		<-ch
+	}
+	cancel()
+	// the producer may still win a few sends against the cancellation.
+	for range ch {
+		received++
+	}
+	if m.Size()+received != 100 {
+		t.Fatalf("received %d entries and %d are left, want 100 in total", received, m.Size())
+	}
+	m.EachKey(func(key string) {
+		if _, ttl, ok := m.GetWithTTL(key); !ok || ttl == NoExpiration {
+			t.Fatalf("%s was put back without its ttl", key)
+		}
+	})
+}
+
+func TestDrainReportsExpired(t *testing.T) {
+	m := fill(New(), 10)
+	m.SetWithTTL("expired", 1, time.Millisecond)
+	var evicted []string
+	m.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	time.Sleep(2 * time.Millisecond)
+
+	received := 0
+	for item := range m.Drain(context.Background()) {
+		if item.Key == "expired" {
+			t.Fatal("Drain sent an expired entry")
+		}
+		received++
+	}
+	if received != 10 || len(evicted) != 1 || evicted[0] != "expired" {
+		t.Fatalf("Drain sent %d entries and evicted %v", received, evicted)
+	}
+}
+
+func TestEachItemErrStopsAtError(t *testing.T) {
+	m := fill(New(), 100)
+	errStop := errors.New("stop")
+	visited := 0
+	err := m.EachItemErr(func(item *Item) error {
+		visited++
+		if visited == 10 {
+			return errStop
+		}
+		m.Set(item.Key, -1) // no lock is held while fn runs
+		return nil
+	})
+	if err != errStop || visited != 10 {
+		t.Fatalf("EachItemErr = %v after %d items, want errStop at the 10th", err, visited)
+	}
+
+	visited = 0
+	if err := m.EachItemErr(func(*Item) error { visited++; return nil }); err != nil || visited != 100 {
+		t.Fatalf("EachItemErr without errors = %v after %d items", err, visited)
+	}
+}
+
+func TestEachItemMutable(t *testing.T) {
+	m := fill(NewWithShard(4), 1000)
+	m.EachItemMutable(func(item *Item) (Op, interface{}) {
+		switch n := item.Value.(int); {
+		case n%2 == 0:
+			return OpDelete, nil
+		case n%3 == 0:
+			return OpSet, -n
+		default:
+			return OpKeep, nil
+		}
+	})
+
+	if m.Size() != 500 {
+		t.Fatalf("Size() = %d after deleting the even values, want 500", m.Size())
+	}
+	m.EachItem(func(item *Item) {
+		n := mustAtoi(t, item.Key)
+		want := n
+		if n%3 == 0 {
+			want = -n
+		}
+		if n%2 == 0 || item.Value != want {
+			t.Fatalf("%s = %v, want %d", item.Key, item.Value, want)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package syncmap
+
+import (
+	"encoding/json"
+)
+
+// MarshalJSON encodes the map as a JSON object, each shard is snapshotted
+// under its read lock.
+func (m *SyncMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Items())
+}
+
+// UnmarshalJSON adds the members of a JSON object to the map, values are
+// decoded with the encoding/json defaults. A zero SyncMap is initialized with
+// the default shard count.
+func (m *SyncMap) UnmarshalJSON(data []byte) error {
+	var items map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	if m.shards == nil {
+		m.init(defaultShardCount, nil)
+	}
+	m.MSet(items)
+	return nil
+}
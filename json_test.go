@@ -0,0 +1,55 @@
+package syncmap
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	m := New()
+	m.Set("string", "v")
+	m.Set("number", 1.5)
+	m.Set("list", []interface{}{"a", true})
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SyncMap
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Items(), m.Items()) {
+		t.Fatalf("round trip gave %v, want %v", decoded.Items(), m.Items())
+	}
+
+	into := NewWithShard(4)
+	if err := json.Unmarshal([]byte(`{"a":1}`), into); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := into.Get("a"); v != 1.0 {
+		t.Fatalf("Get(a) = %v, want the float64 1", v)
+	}
+	if err := json.Unmarshal([]byte(`[1]`), into); err == nil {
+		t.Fatal("decoding an array did not fail")
+	}
+}
+
+func TestMarshalJSONWhileWriting(t *testing.T) {
+	m := fill(New(), 1000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			m.Set(strconv.Itoa(i%2000), i)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if _, err := json.Marshal(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
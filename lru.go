@@ -0,0 +1,182 @@
+package syncmap
+
+import (
+	"container/list"
+	"sync/atomic"
+	"time"
+)
+
+// NewLRU bounds the map to maxEntries, each shard keeps its own recency list
+// and evicts its least recently used key once it holds more than its share,
+// so the eviction order only approximates a global LRU and a shard may evict
+// before the map is full. The shard count is lowered to at most maxEntries.
+// Evicted entries are reported to the OnEvicted callback.
+func NewLRU(maxEntries int, shardCount int) *SyncMap {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	m := new(SyncMap)
+	m.maxEntries = maxEntries
+	m.init(shardCount, nil)
+	return m
+}
+
+func (sd *ShardMap) initLRU(maxEntries int) {
+	sd.lru = list.New()
+	sd.lruIndex = make(map[string]*list.Element)
+	sd.maxEntries = maxEntries
+}
+
+// GetAndTouch returns the value of key and, under the same write lock, marks
+// it most recently used and counts the hit for maps built by NewLRU,
+// NewWithMaxBytes or NewWithMetadata. Get does the same on those maps, this
+// spells it out and bypasses the lock free read of NewCOW maps.
+func (m *SyncMap) GetAndTouch(key string) (interface{}, bool) {
+	shard := m.lockKey(key)
+	v, ok := shard.getAndTouchNotLock(key)
+	shard.Unlock()
+	return v, ok
+}
+
+// getAndTouch reports a miss on a retired shard, Get then retries on the new
+// shards.
+func (sd *ShardMap) getAndTouch(key string) (interface{}, bool) {
+	sd.Lock()
+	if sd.retired.Load() {
+		sd.RWMutex.Unlock()
+		return nil, false
+	}
+	v, ok := sd.getAndTouchNotLock(key)
+	sd.Unlock()
+	return v, ok
+}
+
+func (sd *ShardMap) getAndTouchNotLock(key string) (interface{}, bool) {
+	v, ok := sd.items[key]
+	if ok && sd.expiredNotLock(key, time.Now().UnixNano()) {
+		sd.DeleteNotLock(key)
+		ok = false
+		v = nil
+	}
+	if ok {
+		if sd.lru != nil {
+			sd.touchNotLock(key)
+		}
+		if sd.meta != nil {
+			sd.meta[key].hit()
+		}
+	}
+	return v, ok
+}
+
+func (sd *ShardMap) touchNotLock(key string) {
+	if elem, ok := sd.lruIndex[key]; ok {
+		sd.lru.MoveToFront(elem)
+		return
+	}
+	sd.lruIndex[key] = sd.lru.PushFront(key)
+}
+
+func (sd *ShardMap) evictOverflowNotLock() {
+	if sd.budget != nil {
+		if newest := sd.lru.Front(); newest != nil && sd.sizes[newest.Value.(string)] > sd.budget.max {
+			sd.DeleteNotLock(newest.Value.(string))
+		}
+	}
+	for len(sd.items) > sd.maxEntries || (sd.budget.over() && len(sd.items) > 1) {
+		oldest := sd.lru.Back()
+		if oldest == nil {
+			return
+		}
+		sd.DeleteNotLock(oldest.Value.(string))
+	}
+}
+
+// NewWithMaxBytes bounds the estimated size of the map, an entry weighs the
+// length of its key plus sizer(value), or the key alone when sizer is nil.
+// The budget is global: a write that goes over it evicts the least recently
+// used keys of its own shard, then of the other shards in turn once the
+// shard lock is released. An entry larger than maxBytes is evicted right
+// away. Keys are only evicted from shards that are not locked at the time,
+// so the map may exceed maxBytes briefly under contention.
+func NewWithMaxBytes(maxBytes int64, sizer func(value interface{}) int64) *SyncMap {
+	if maxBytes < 1 {
+		maxBytes = 1
+	}
+	m := new(SyncMap)
+	m.maxBytes = maxBytes
+	m.sizer = sizer
+	m.init(defaultShardCount, nil)
+	return m
+}
+
+// Bytes is the estimated size of a map built by NewWithMaxBytes, it is
+// always zero for other maps.
+func (m *SyncMap) Bytes() int64 {
+	if m.hooks.budget == nil {
+		return 0
+	}
+	return m.hooks.budget.total.Load()
+}
+
+// byteBudget tracks the bytes of all shards of a map against maxBytes.
+type byteBudget struct {
+	max   int64
+	total atomic.Int64
+	// cursor spreads the evictions of enforce over the shards.
+	cursor atomic.Uint32
+	m      *SyncMap
+}
+
+func (b *byteBudget) add(delta int64) {
+	b.total.Add(delta)
+}
+
+func (b *byteBudget) over() bool {
+	return b != nil && b.total.Load() > b.max
+}
+
+// enforce evicts the least recently used key of each shard in turn until the
+// map is back under budget, and returns the evicted entries. It only try
+// locks and never blocks: busy shards are skipped and it gives up while a
+// Resize holds m.mu.
+func (b *byteBudget) enforce() (evicted []Item) {
+	if !b.over() || !b.m.mu.TryRLock() {
+		return nil
+	}
+	shards := b.m.shards
+	for progress := true; progress && b.over(); {
+		progress = false
+		for range shards {
+			if !b.over() {
+				break
+			}
+			shard := shards[b.cursor.Add(1)%uint32(len(shards))]
+			if !shard.TryLock() {
+				continue
+			}
+			if oldest := shard.lru.Back(); oldest != nil {
+				shard.DeleteNotLock(oldest.Value.(string))
+				progress = true
+			}
+			evicted = shard.unlockDeferred(evicted)
+		}
+	}
+	b.m.mu.RUnlock()
+	return evicted
+}
+
+func (sd *ShardMap) addBytes(delta int64) {
+	sd.bytes += delta
+	if sd.budget != nil {
+		sd.budget.add(delta)
+	}
+}
+
+func (sd *ShardMap) sizeOf(key string, value interface{}) int64 {
+	size := int64(len(key))
+	if sd.sizer != nil {
+		size += sd.sizer(value)
+	}
+	return size
+}
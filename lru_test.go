@@ -0,0 +1,142 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestLRUEvictsOldest(t *testing.T) {
+	m := NewLRU(3, 1)
+	var evicted []string
+	m.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Get("a") // a is now the most recently used
+	m.Set("d", 4)
+	m.Set("e", 5)
+
+	if m.Size() != 3 {
+		t.Fatalf("Size() = %d, want 3", m.Size())
+	}
+	for _, key := range []string{"a", "d", "e"} {
+		if !m.Has(key) {
+			t.Fatalf("%s was evicted, want b and c evicted first", key)
+		}
+	}
+	if len(evicted) != 2 || evicted[0] != "b" || evicted[1] != "c" {
+		t.Fatalf("evicted %v, want [b c]", evicted)
+	}
+}
+
+func TestLRUBound(t *testing.T) {
+	for _, tc := range []struct{ maxEntries, shardCount int }{
+		{10, 0}, {1, 0}, {100, 16}, {1000, 128}, {130, 128},
+	} {
+		m := NewLRU(tc.maxEntries, tc.shardCount)
+		if m.ShardCount() > tc.maxEntries {
+			t.Fatalf("NewLRU(%d, %d) has %d shards", tc.maxEntries, tc.shardCount, m.ShardCount())
+		}
+		fill(m, 10*tc.maxEntries)
+		if n := m.Size(); n > tc.maxEntries {
+			t.Fatalf("NewLRU(%d, %d) holds %d entries", tc.maxEntries, tc.shardCount, n)
+		}
+		m.Resize(1024)
+		fill(m, 10*tc.maxEntries)
+		if n := m.Size(); n > tc.maxEntries {
+			t.Fatalf("NewLRU(%d, %d) holds %d entries after Resize", tc.maxEntries, tc.shardCount, n)
+		}
+	}
+}
+
+func TestLRUKeepsTouchedKeys(t *testing.T) {
+	m := NewLRU(100, 4)
+	for i := 0; i < 1000; i++ {
+		m.Set(strconv.Itoa(i), i)
+		m.Get("0")
+	}
+	if !m.Has("0") {
+		t.Fatal("the key read after every Set was evicted")
+	}
+	if m.Has("1") {
+		t.Fatal("an untouched early key survived 1000 inserts")
+	}
+}
+
+func TestMaxBytesEvictsPastBudget(t *testing.T) {
+	const maxBytes = 10000
+	m := NewWithMaxBytes(maxBytes, func(interface{}) int64 { return 100 })
+	var evicted int
+	m.OnEvicted(func(key string, value interface{}) {
+		evicted++
+	})
+
+	for i := 0; i < 1000; i++ {
+		m.Set(strconv.Itoa(i), i)
+		if m.Bytes() > maxBytes {
+			t.Fatalf("Bytes() = %d > %d after %d inserts", m.Bytes(), maxBytes, i+1)
+		}
+	}
+	if m.Size() < 90 || m.Size()+evicted != 1000 {
+		t.Fatalf("%d entries kept and %d evicted out of 1000", m.Size(), evicted)
+	}
+
+	var total int64
+	m.EachItem(func(item *Item) {
+		total += int64(len(item.Key)) + 100
+	})
+	if total != m.Bytes() {
+		t.Fatalf("Bytes() = %d, the entries weigh %d", m.Bytes(), total)
+	}
+}
+
+func TestMaxBytesKeepsEntriesUnderBudget(t *testing.T) {
+	m := NewWithMaxBytes(10000, func(value interface{}) int64 {
+		if b, ok := value.([]byte); ok {
+			return int64(len(b))
+		}
+		return 100
+	})
+	for i := 0; i < 50; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+	if m.Size() != 50 {
+		t.Fatalf("Size() = %d, want all 50 entries of a map under budget", m.Size())
+	}
+	m.Resize(16)
+	if m.Size() != 50 || m.Bytes() != 50*100+90 {
+		t.Fatalf("after Resize: %d entries weighing %d", m.Size(), m.Bytes())
+	}
+
+	m.Set("huge", make([]byte, 20000))
+	if m.Has("huge") || m.Size() != 50 || m.Bytes() != 50*100+90 {
+		t.Fatalf("an entry larger than the budget was kept or evicted others: %d entries", m.Size())
+	}
+}
+
+func TestGetAndTouchKeepsKeyOnLRU(t *testing.T) {
+	m := NewLRU(3, 1)
+	m.Set("touched", 0)
+	for i := 0; i < 100; i++ {
+		m.Set(strconv.Itoa(i), i)
+		if value, ok := m.GetAndTouch("touched"); !ok || value != 0 {
+			t.Fatalf("the touched key was evicted by Set %d", i)
+		}
+	}
+	if m.Size() != 3 || !m.Has("99") || !m.Has("98") || m.Has("97") {
+		t.Fatal("an untouched key outlived newer ones")
+	}
+	if _, ok := m.GetAndTouch("0"); ok {
+		t.Fatal("GetAndTouch returned an evicted key")
+	}
+
+	meta := NewWithMetadata()
+	meta.Set("k", 1)
+	meta.GetAndTouch("k")
+	if _, _, _, hits, _ := meta.GetMeta("k"); hits != 1 {
+		t.Fatalf("GetAndTouch counted %d hits on a metadata map, want 1", hits)
+	}
+}
@@ -0,0 +1,68 @@
+package syncmap
+
+import (
+	"path"
+	"strings"
+)
+
+func (m *SyncMap) KeysWithPrefix(prefix string) []string {
+	var keys []string
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key := range shard.items {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		shard.RUnlock()
+	}
+	return keys
+}
+
+// KeysMatch returns the keys matching a glob pattern with the syntax of
+// path.Match: * and ? don't match a '/'. It fails for a malformed pattern.
+func (m *SyncMap) KeysMatch(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key := range shard.items {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		shard.RUnlock()
+	}
+	return keys, nil
+}
+
+// DeleteWithPrefix removes every key starting with prefix and returns how
+// many were removed.
+func (m *SyncMap) DeleteWithPrefix(prefix string) int {
+	var (
+		removed int
+		evicted []Item
+		keys    []string
+	)
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		keys = keys[:0]
+		shard.Lock()
+		for key := range shard.items {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			shard.DeleteNotLock(key)
+		}
+		removed += len(keys)
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+	return removed
+}
@@ -0,0 +1,81 @@
+package syncmap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestKeysWithPrefix(t *testing.T) {
+	m := New()
+	for _, key := range []string{
+		"tenant:1:session:a", "tenant:1:session:b", "tenant:12:session:c",
+		"tenant:2:session:d", "other:tenant:1:x", "tenant:1",
+	} {
+		m.Set(key, nil)
+	}
+
+	keys := m.KeysWithPrefix("tenant:1:")
+	sort.Strings(keys)
+	want := []string{"tenant:1:session:a", "tenant:1:session:b"}
+	if len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] {
+		t.Fatalf("KeysWithPrefix(tenant:1:) = %v, want %v", keys, want)
+	}
+	if keys := m.KeysWithPrefix("missing"); len(keys) != 0 {
+		t.Fatalf("KeysWithPrefix(missing) = %v", keys)
+	}
+	if keys := m.KeysWithPrefix(""); len(keys) != m.Size() {
+		t.Fatalf("KeysWithPrefix(\"\") returned %d keys, want all %d", len(keys), m.Size())
+	}
+}
+
+func TestDeleteWithPrefix(t *testing.T) {
+	m := New()
+	for tenant := 1; tenant <= 3; tenant++ {
+		for i := 0; i < 100; i++ {
+			m.Set("tenant:"+strconv.Itoa(tenant)+":"+strconv.Itoa(i), i)
+		}
+	}
+	m.Set("tenant:1", "bare")
+	m.Set("tenant:10:0", "other tenant")
+
+	if n := m.DeleteWithPrefix("tenant:1:"); n != 100 {
+		t.Fatalf("DeleteWithPrefix removed %d keys, want 100", n)
+	}
+	if m.Size() != 202 || len(m.KeysWithPrefix("tenant:1:")) != 0 {
+		t.Fatalf("%d entries left, want 202 without tenant:1:", m.Size())
+	}
+	if !m.Has("tenant:1") || !m.Has("tenant:10:0") || !m.Has("tenant:2:0") || !m.Has("tenant:3:99") {
+		t.Fatal("DeleteWithPrefix removed keys outside the prefix")
+	}
+	if n := m.DeleteWithPrefix("tenant:1:"); n != 0 {
+		t.Fatalf("second DeleteWithPrefix removed %d keys", n)
+	}
+}
+
+func TestKeysMatch(t *testing.T) {
+	m := New()
+	for _, key := range []string{"user:1", "user:22", "users", "cat", "bat", "at", "chat", "user:a/b"} {
+		m.Set(key, nil)
+	}
+
+	for pattern, want := range map[string][]string{
+		"user:*": {"user:1", "user:22"},
+		"?at":    {"bat", "cat"},
+		"x*":     {},
+	} {
+		keys, err := m.KeysMatch(pattern)
+		if err != nil {
+			t.Fatalf("KeysMatch(%q): %v", pattern, err)
+		}
+		sort.Strings(keys)
+		if strings.Join(keys, ",") != strings.Join(want, ",") {
+			t.Fatalf("KeysMatch(%q) = %v, want %v", pattern, keys, want)
+		}
+	}
+
+	if keys, err := m.KeysMatch("user:[1"); err == nil {
+		t.Fatalf("KeysMatch with an invalid pattern = %v, want an error", keys)
+	}
+}
@@ -0,0 +1,44 @@
+package syncmap
+
+import (
+	"time"
+)
+
+type entryMeta struct {
+	createdAt  time.Time
+	lastAccess time.Time
+	hits       int64
+}
+
+func newEntryMeta() *entryMeta {
+	now := time.Now()
+	return &entryMeta{createdAt: now, lastAccess: now}
+}
+
+func (e *entryMeta) hit() {
+	e.lastAccess = time.Now()
+	e.hits++
+}
+
+// NewWithMetadata records when each key was created, when it was last read
+// and how often, see GetMeta. Get takes the shard write lock to update them.
+func NewWithMetadata() *SyncMap {
+	m := new(SyncMap)
+	m.metadata = true
+	m.init(defaultShardCount, nil)
+	return m
+}
+
+// GetMeta returns the value of key with its metadata without counting as an
+// access, ok is false for a missing key or a map without metadata.
+func (m *SyncMap) GetMeta(key string) (value interface{}, createdAt, lastAccess time.Time, hits int64, ok bool) {
+	shard := m.rlockKey(key)
+	defer shard.RUnlock()
+
+	value, ok = shard.GetNotLock(key)
+	meta, tracked := shard.meta[key]
+	if !ok || !tracked {
+		return nil, time.Time{}, time.Time{}, 0, false
+	}
+	return value, meta.createdAt, meta.lastAccess, meta.hits, true
+}
@@ -0,0 +1,41 @@
+package syncmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetadataHitsAndTimes(t *testing.T) {
+	m := NewWithMetadata()
+	before := time.Now()
+	m.Set("k", "v")
+
+	value, createdAt, lastAccess, hits, ok := m.GetMeta("k")
+	if !ok || value != "v" || hits != 0 {
+		t.Fatalf("GetMeta after Set = %v, %d hits, %v", value, hits, ok)
+	}
+	if createdAt.Before(before) || !lastAccess.Equal(createdAt) {
+		t.Fatalf("createdAt %v, lastAccess %v, set after %v", createdAt, lastAccess, before)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		m.Get("k")
+	}
+	_, created, lastAccess, hits, _ := m.GetMeta("k")
+	if hits != 3 {
+		t.Fatalf("hits = %d after 3 Gets", hits)
+	}
+	if !created.Equal(createdAt) || !lastAccess.After(createdAt) {
+		t.Fatalf("createdAt %v, lastAccess %v after reads", created, lastAccess)
+	}
+
+	if _, _, _, _, ok := m.GetMeta("missing"); ok {
+		t.Fatal("GetMeta reported a missing key")
+	}
+	plain := New()
+	plain.Set("k", "v")
+	if _, _, _, _, ok := plain.GetMeta("k"); ok {
+		t.Fatal("GetMeta reported metadata on a plain map")
+	}
+}
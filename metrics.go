@@ -0,0 +1,37 @@
+package syncmap
+
+import "sync/atomic"
+
+type mapMetrics struct {
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	sets    atomic.Uint64
+	deletes atomic.Uint64
+}
+
+func (mm *mapMetrics) get(hit bool) {
+	if hit {
+		mm.hits.Add(1)
+		return
+	}
+	mm.misses.Add(1)
+}
+
+// NewWithMetrics counts the outcome of every Get, Set and Delete call with
+// atomics kept outside the shard locks, see Metrics. Maps from the other
+// constructors skip the counting entirely.
+func NewWithMetrics() *SyncMap {
+	m := new(SyncMap)
+	m.metrics = new(mapMetrics)
+	m.init(defaultShardCount, nil)
+	return m
+}
+
+// Metrics reports the counters of a map built by NewWithMetrics, all of them
+// are zero for other maps.
+func (m *SyncMap) Metrics() (hits, misses, sets, deletes uint64) {
+	if m.metrics == nil {
+		return 0, 0, 0, 0
+	}
+	return m.metrics.hits.Load(), m.metrics.misses.Load(), m.metrics.sets.Load(), m.metrics.deletes.Load()
+}
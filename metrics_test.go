@@ -0,0 +1,31 @@
+package syncmap
+
+import (
+	"testing"
+)
+
+func TestMetricsCounters(t *testing.T) {
+	m := NewWithMetrics()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("a", 3)
+	for i := 0; i < 4; i++ {
+		m.Get("a")
+	}
+	m.Get("missing")
+	m.Get("missing")
+	m.Delete("b")
+	m.Delete("missing")
+
+	hits, misses, sets, deletes := m.Metrics()
+	if hits != 4 || misses != 2 || sets != 3 || deletes != 2 {
+		t.Fatalf("Metrics() = %d hits, %d misses, %d sets, %d deletes, want 4, 2, 3, 2", hits, misses, sets, deletes)
+	}
+
+	plain := New()
+	plain.Set("a", 1)
+	plain.Get("a")
+	if hits, misses, sets, deletes := plain.Metrics(); hits+misses+sets+deletes != 0 {
+		t.Fatal("a plain map counted metrics")
+	}
+}
@@ -0,0 +1,41 @@
+package syncmap
+
+import (
+	"time"
+)
+
+// NewOrdered remembers the insertion order of keys in every shard so that
+// PopOldest can drain the map like a queue. Overwriting a key keeps its
+// position.
+func NewOrdered() *SyncMap {
+	m := new(SyncMap)
+	m.ordered = true
+	m.init(defaultShardCount, nil)
+	return m
+}
+
+// PopOldest removes the earliest inserted entry of a shard, the shards take
+// turns so the order is exact within a shard and only approximate across
+// them. ok is false when the map is empty or was not built by NewOrdered.
+// Expired entries are dropped like in Pop.
+func (m *SyncMap) PopOldest() (key string, value interface{}, ok bool) {
+	now := time.Now().UnixNano()
+	walk := func(shardCount int) (int, int) {
+		return int(m.popCursor.Add(1) % uint32(shardCount)), 1
+	}
+	m.walkShards(walk, func(shard *ShardMap) bool {
+		for !ok && shard.order != nil && shard.order.Len() > 0 {
+			key = shard.order.Front().Value.(string)
+			if shard.expiredNotLock(key, now) {
+				shard.DeleteNotLock(key)
+				continue
+			}
+			value, ok = shard.takeNotLock(key)
+		}
+		return !ok
+	})
+	if !ok {
+		return "", nil, false
+	}
+	return key, value, true
+}
@@ -0,0 +1,58 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPopOldestInsertionOrder(t *testing.T) {
+	m := NewOrdered()
+	for i := 0; i < 1000; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+	m.Set("0", "rewritten") // a rewrite keeps the original position
+
+	last := make(map[int]int)
+	for n := 0; n < 1000; n++ {
+		key, value, ok := m.PopOldest()
+		if !ok {
+			t.Fatalf("PopOldest ran dry after %d entries", n)
+		}
+		i := mustAtoi(t, key)
+		shard := m.ShardIndex(key)
+		if prev, seen := last[shard]; seen && prev > i {
+			t.Fatalf("shard %d popped %d after %d", shard, i, prev)
+		}
+		last[shard] = i
+		if key == "0" && value != "rewritten" {
+			t.Fatalf("PopOldest(0) = %v", value)
+		}
+	}
+	if _, _, ok := m.PopOldest(); ok || !m.IsEmpty() {
+		t.Fatal("PopOldest returned an entry from an empty map")
+	}
+}
+
+func TestPopOldestSkipsExpired(t *testing.T) {
+	m := NewOrdered()
+	m.SetWithTTL("old", 1, time.Nanosecond)
+	m.Set("new", 2)
+	time.Sleep(time.Millisecond)
+
+	for {
+		key, _, ok := m.PopOldest()
+		if !ok {
+			break
+		}
+		if key == "old" {
+			t.Fatal("PopOldest returned an expired entry")
+		}
+	}
+	if !m.IsEmpty() {
+		t.Fatal("the expired entry was left behind")
+	}
+	if _, _, ok := New().PopOldest(); ok {
+		t.Fatal("PopOldest on a map not built by NewOrdered reported an entry")
+	}
+}
@@ -0,0 +1,43 @@
+package syncmap
+
+// ReadOnlyMap is a live read only view of a SyncMap, it shares the shards of
+// the map and so sees every later write.
+type ReadOnlyMap struct {
+	m *SyncMap
+}
+
+func (m *SyncMap) ReadOnly() *ReadOnlyMap {
+	return &ReadOnlyMap{m: m}
+}
+
+func (r *ReadOnlyMap) Get(key string) (interface{}, bool) {
+	return r.m.Get(key)
+}
+
+func (r *ReadOnlyMap) Has(key string) bool {
+	return r.m.Has(key)
+}
+
+func (r *ReadOnlyMap) Size() int {
+	return r.m.Size()
+}
+
+func (r *ReadOnlyMap) Keys() []string {
+	return r.m.Keys()
+}
+
+func (r *ReadOnlyMap) EachKey(iter IterKeyFunc) {
+	r.m.EachKey(iter)
+}
+
+func (r *ReadOnlyMap) EachKeyWithBreak(iter IterKeyWithBreakFunc) {
+	r.m.EachKeyWithBreak(iter)
+}
+
+func (r *ReadOnlyMap) EachItem(iter IterItemFunc) {
+	r.m.EachItem(iter)
+}
+
+func (r *ReadOnlyMap) EachItemWithBreak(iter IterItemWithBreakFunc) {
+	r.m.EachItemWithBreak(iter)
+}
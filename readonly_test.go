@@ -0,0 +1,29 @@
+package syncmap
+
+import (
+	"testing"
+)
+
+func TestReadOnlyReflectsWrites(t *testing.T) {
+	m := New()
+	view := m.ReadOnly()
+	if view.Size() != 0 || view.Has("a") {
+		t.Fatal("the view of an empty map is not empty")
+	}
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	if value, ok := view.Get("a"); !ok || value != 1 || view.Size() != 2 || len(view.Keys()) != 2 {
+		t.Fatal("the view does not show later writes")
+	}
+
+	m.Set("a", 3)
+	m.Delete("b")
+	sum := 0
+	view.EachItem(func(item *Item) {
+		sum += item.Value.(int)
+	})
+	if sum != 3 || view.Has("b") {
+		t.Fatalf("the view saw sum %d, has b %v after an overwrite and a delete", sum, view.Has("b"))
+	}
+}
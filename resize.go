@@ -0,0 +1,132 @@
+package syncmap
+
+import (
+	"time"
+)
+
+// Resize rehashes every entry into newShardCount shards, rounded up to a
+// power of two like NewWithShard unless the map was built by NewConsistent
+// or NewWithPlacement. Each old shard is write locked while its entries
+// move, operations on a moved shard and those spanning several shards wait
+// for the whole copy to finish, so treat it as a rare maintenance step.
+// Shards obtained from Locate or GetShards before the call are detached from
+// the map afterwards, writes made through them are lost.
+func (m *SyncMap) Resize(newShardCount int) {
+	if newShardCount <= 0 {
+		newShardCount = defaultShardCount
+	}
+	requested := newShardCount
+	newShardCount = m.roundShardCount(newShardCount)
+
+	m.mu.Lock()
+	m.requested = requested
+	if newShardCount == m.shardCount {
+		m.mu.Unlock()
+		return
+	}
+
+	old := m.shards
+	m.shardCount = newShardCount
+	if m.autoHash {
+		m.hasher = defaultHasher(newShardCount)
+	}
+	m.shards = make([]*ShardMap, newShardCount)
+	for i := range m.shards {
+		m.shards[i] = m.newShard(i)
+	}
+
+	// the new shards are unreachable until m.mu is released, fillNotLock
+	// write locks the old ones to wait for operations still running on them.
+	evicted := m.fillNotLock(old, false, nil)
+	m.table.Store(&shardTable{m.shards, m.hasher})
+	m.mu.Unlock()
+	m.hooks.notify(evicted)
+}
+
+// fillNotLock re-inserts the entries of src into the shards of m with their
+// ttl, version and metadata, in the order orderedItemsNotLock gives. The
+// shards of m must not be reachable by other goroutines yet. A Resize moves
+// the entries out of write locked shards as they are, a copy only read locks
+// src, leaves the metadata of src alone and skips expired entries and those
+// keep rejects. It returns the entries the bounds of m evicted.
+func (m *SyncMap) fillNotLock(src []*ShardMap, copying bool, keep PredicateFunc) (evicted []Item) {
+	for _, shard := range m.shards {
+		shard.quiet = true
+	}
+	now := time.Now().UnixNano()
+	for _, shard := range src {
+		if copying {
+			shard.RLock()
+		} else {
+			shard.Lock()
+			// the entries are counted again as they land in m.
+			if shard.budget != nil {
+				shard.budget.add(-shard.bytes)
+				shard.budget = nil
+			}
+		}
+		for _, item := range shard.orderedItemsNotLock() {
+			if copying && (shard.expiredNotLock(item.Key, now) || keep != nil && !keep(item.Key, item.Value)) {
+				continue
+			}
+			dst := m.locate(item.Key)
+			dst.SetNotLock(item.Key, item.Value)
+			if _, ok := dst.items[item.Key]; !ok {
+				continue
+			}
+			if deadline, ok := shard.expires[item.Key]; ok {
+				dst.setDeadlineNotLock(item.Key, deadline)
+			}
+			if version, ok := shard.versions[item.Key]; ok {
+				dst.setVersionNotLock(item.Key, version)
+			}
+			if meta, ok := shard.meta[item.Key]; ok && dst.meta != nil {
+				if copying {
+					copied := *meta
+					meta = &copied
+				}
+				dst.meta[item.Key] = meta
+			}
+		}
+		if copying {
+			shard.RUnlock()
+		} else {
+			shard.retired.Store(true)
+			shard.Unlock()
+		}
+	}
+	for _, shard := range m.shards {
+		evicted = append(evicted, shard.evicted...)
+		shard.evicted = nil
+		shard.quiet = false
+		if shard.view != nil {
+			shard.view.publish(shard)
+		}
+	}
+	return evicted
+}
+
+// orderedItemsNotLock lists the entries in insertion order, or from least to
+// most recently used when the shard tracks recency, so re-inserting them
+// keeps that order.
+func (sd *ShardMap) orderedItemsNotLock() []Item {
+	items := make([]Item, 0, len(sd.items))
+	if sd.order != nil {
+		for elem := sd.order.Front(); elem != nil; elem = elem.Next() {
+			key := elem.Value.(string)
+			items = append(items, Item{key, sd.items[key]})
+		}
+		return items
+	}
+	if sd.lru != nil {
+		for elem := sd.lru.Back(); elem != nil; elem = elem.Prev() {
+			key := elem.Value.(string)
+			items = append(items, Item{key, sd.items[key]})
+		}
+		return items
+	}
+	for key, value := range sd.items {
+		items = append(items, Item{key, value})
+	}
+	return items
+}
@@ -0,0 +1,65 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestResizeKeepsKeys(t *testing.T) {
+	m := fill(NewWithShard(8), 10000)
+	m.Resize(64)
+	if m.ShardCount() != 64 || m.Size() != 10000 {
+		t.Fatalf("after Resize(64): %d shards, %d entries", m.ShardCount(), m.Size())
+	}
+	for i := 0; i < 10000; i++ {
+		if value, ok := m.Get(strconv.Itoa(i)); !ok || value != i {
+			t.Fatalf("Get(%d) = %v, %v after Resize", i, value, ok)
+		}
+	}
+	if n := m.Flush(); n != 10000 || m.Size() != 0 {
+		t.Fatalf("Flush after Resize removed %d entries", n)
+	}
+}
+
+func TestResizeWhileWriting(t *testing.T) {
+	m := fill(NewWithShard(8), 1000)
+	parallel(4, func(g int) {
+		if g == 0 {
+			m.Resize(64)
+			m.Resize(16)
+			return
+		}
+		for i := 0; i < 1000; i++ {
+			m.Set(strconv.Itoa(g)+"-"+strconv.Itoa(i), i)
+			m.Get(strconv.Itoa(i))
+		}
+	})
+	if m.ShardCount() != 16 || m.Size() != 4000 {
+		t.Fatalf("%d shards, %d entries, want 16 and 4000", m.ShardCount(), m.Size())
+	}
+}
+
+func TestResizeLosesNoWrites(t *testing.T) {
+	m := NewWithShard(8)
+	parallel(4, func(g int) {
+		if g == 0 {
+			for n := 16; n <= 256; n *= 2 {
+				m.Resize(n)
+			}
+			return
+		}
+		for i := 0; i < 2000; i++ {
+			key := strconv.Itoa(g) + "-" + strconv.Itoa(i)
+			m.Set(key, i)
+			if value, ok := m.Get(key); !ok || value != i {
+				t.Errorf("Get(%s) = %v, %v right after Set", key, value, ok)
+			}
+			if i%2 == 0 {
+				m.Delete(key)
+			}
+		}
+	})
+	if m.ShardCount() != 256 || m.Size() != 3000 {
+		t.Fatalf("%d shards, %d entries, want 256 and 3000", m.ShardCount(), m.Size())
+	}
+}
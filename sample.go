@@ -0,0 +1,92 @@
+package syncmap
+
+import (
+	"math/rand"
+)
+
+// RandomKey returns a random key without removing it, ok is false when the
+// map is empty.
+func (m *SyncMap) RandomKey() (key string, ok bool) {
+	shards := m.GetShards()
+	start := rand.Intn(len(shards))
+	for i := range shards {
+		shard := shards[(start+i)%len(shards)]
+		shard.RLock()
+		item, found := shard.randomItemNotLock()
+		shard.RUnlock()
+		if found {
+			return item.Key, true
+		}
+	}
+	return "", false
+}
+
+func (sd *ShardMap) randomItemNotLock() (Item, bool) {
+	if len(sd.items) == 0 {
+		return Item{}, false
+	}
+	n := rand.Intn(len(sd.items))
+	for key, value := range sd.items {
+		if n == 0 {
+			return Item{key, value}, true
+		}
+		n--
+	}
+	return Item{}, false
+}
+
+// sampleAttempts bounds the random probes SampleN makes per requested item
+// before it falls back to scanning the shards.
+const sampleAttempts = 4
+
+// SampleN returns up to n distinct entries picked at random. It samples a
+// random shard and then a random key inside it, so keys of sparse shards are
+// favoured and the distribution is only approximately uniform. The shards
+// are only scanned once random probing stops finding new keys.
+func (m *SyncMap) SampleN(n int) []Item {
+	size := m.Size()
+	if n > size {
+		n = size
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	var (
+		items  = make([]Item, 0, n)
+		seen   = make(map[string]struct{}, n)
+		shards = m.GetShards()
+	)
+	for attempt := 0; attempt < n*sampleAttempts && len(items) < n; attempt++ {
+		shard := shards[rand.Intn(len(shards))]
+		shard.RLock()
+		item, ok := shard.randomItemNotLock()
+		shard.RUnlock()
+		if !ok {
+			continue
+		}
+		if _, dup := seen[item.Key]; dup {
+			continue
+		}
+		seen[item.Key] = struct{}{}
+		items = append(items, item)
+	}
+
+	start := rand.Intn(len(shards))
+	for i := 0; i < len(shards) && len(items) < n; i++ {
+		shard := shards[(start+i)%len(shards)]
+		shard.RLock()
+		for key, value := range shard.items {
+			if len(items) == n {
+				break
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			items = append(items, Item{key, value})
+		}
+		shard.RUnlock()
+	}
+	return items
+}
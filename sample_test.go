@@ -0,0 +1,48 @@
+package syncmap
+
+import (
+	"testing"
+)
+
+func TestRandomKey(t *testing.T) {
+	m := New()
+	if _, ok := m.RandomKey(); ok {
+		t.Fatal("RandomKey on an empty map reported a key")
+	}
+
+	fill(m, 1000)
+	for i := 0; i < 100; i++ {
+		key, ok := m.RandomKey()
+		if !ok || !m.Has(key) {
+			t.Fatalf("RandomKey() = %q, %v, want an existing key", key, ok)
+		}
+	}
+	if m.Size() != 1000 {
+		t.Fatalf("RandomKey removed entries, size %d", m.Size())
+	}
+}
+
+func TestSampleN(t *testing.T) {
+	m := fill(New(), 1000)
+	for _, n := range []int{0, 1, 10, 999, 1000, 5000} {
+		items := m.SampleN(n)
+		want := n
+		if want > 1000 {
+			want = 1000
+		}
+		if len(items) != want {
+			t.Fatalf("SampleN(%d) returned %d items, want %d", n, len(items), want)
+		}
+
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			if seen[item.Key] {
+				t.Fatalf("SampleN(%d) returned %q twice", n, item.Key)
+			}
+			seen[item.Key] = true
+			if value, ok := m.Get(item.Key); !ok || value != item.Value {
+				t.Fatalf("SampleN(%d) returned %v which is not in the map", n, item)
+			}
+		}
+	}
+}
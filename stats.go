@@ -0,0 +1,59 @@
+package syncmap
+
+import (
+	"math"
+)
+
+// ShardStats returns the number of entries held by each shard, indexed like
+// GetShards.
+func (m *SyncMap) ShardStats() []int {
+	shards := m.GetShards()
+	stats := make([]int, len(shards))
+	for i, shard := range shards {
+		shard.RLock()
+		stats[i] = len(shard.items)
+		shard.RUnlock()
+	}
+	return stats
+}
+
+// LoadFactorStdDev is the standard deviation of the shard sizes, a value far
+// above the square root of the mean size hints at a poorly spreading hasher.
+func (m *SyncMap) LoadFactorStdDev() float64 {
+	stats := m.ShardStats()
+
+	total := 0
+	for _, n := range stats {
+		total += n
+	}
+	mean := float64(total) / float64(len(stats))
+
+	variance := 0.0
+	for _, n := range stats {
+		d := float64(n) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(stats)))
+}
+
+// entryOverhead approximates what a map slot costs besides the key bytes, a
+// string header and an interface plus the bucket bookkeeping.
+const entryOverhead = 48
+
+// EstimatedBytes is a rough memory estimate of the entries, every entry
+// weighs the length of its key, entryOverhead and valueSizer(value) when
+// valueSizer is not nil. valueSizer runs under the shard read lock.
+func (m *SyncMap) EstimatedBytes(valueSizer func(value interface{}) int64) int64 {
+	var total int64
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key, value := range shard.items {
+			total += int64(len(key)) + entryOverhead
+			if valueSizer != nil {
+				total += valueSizer(value)
+			}
+		}
+		shard.RUnlock()
+	}
+	return total
+}
@@ -0,0 +1,67 @@
+package syncmap
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestShardStatsRevealSkew(t *testing.T) {
+	// every "hot:" key hashes to shard 0, the others spread evenly.
+	hasher := func(key string) uint32 {
+		if strings.HasPrefix(key, "hot:") {
+			return 0
+		}
+		return fnv32(key)
+	}
+	m := NewWithHasher(16, hasher)
+	for _, key := range randomKeys(1600) {
+		m.Set(key, nil)
+	}
+	balanced := m.LoadFactorStdDev()
+	for _, key := range randomKeys(1600) {
+		m.Set("hot:"+key, nil)
+	}
+
+	stats := m.ShardStats()
+	if len(stats) != 16 {
+		t.Fatalf("ShardStats has %d entries, want 16", len(stats))
+	}
+	total := 0
+	for i, n := range stats {
+		total += n
+		if i > 0 && n >= stats[0] {
+			t.Fatalf("shard %d holds %d entries, no fewer than the hot shard's %d", i, n, stats[0])
+		}
+	}
+	if total != m.Size() {
+		t.Fatalf("ShardStats sums to %d, want %d", total, m.Size())
+	}
+	if skewed := m.LoadFactorStdDev(); skewed < 10*balanced || skewed < 10*math.Sqrt(float64(total)/16) {
+		t.Fatalf("LoadFactorStdDev = %.1f, balanced was %.1f", skewed, balanced)
+	}
+}
+
+func TestEstimatedBytes(t *testing.T) {
+	m := New()
+	m.Set("a", "xyz")
+	m.Set("bcd", "")
+	m.Set("ef", 7)
+
+	keys := int64(1 + 3 + 2)
+	if n := m.EstimatedBytes(nil); n != keys+3*entryOverhead {
+		t.Fatalf("EstimatedBytes(nil) = %d, want %d", n, keys+3*entryOverhead)
+	}
+	sizer := func(value interface{}) int64 {
+		if s, ok := value.(string); ok {
+			return int64(len(s))
+		}
+		return 8
+	}
+	if n := m.EstimatedBytes(sizer); n != keys+3*entryOverhead+3+0+8 {
+		t.Fatalf("EstimatedBytes(sizer) = %d, want %d", n, keys+3*entryOverhead+11)
+	}
+	if n := New().EstimatedBytes(sizer); n != 0 {
+		t.Fatalf("EstimatedBytes of an empty map = %d", n)
+	}
+}
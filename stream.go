@@ -0,0 +1,107 @@
+package syncmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// maxFrameSize bounds a single key or value frame read by ReadFrom.
+const maxFrameSize = 1 << 30
+
+var errFrameTooLarge = errors.New("syncmap: frame too large")
+
+// WriteTo streams the entries to w one shard at a time, each as a length
+// prefixed key followed by a length prefixed gob encoded value. Value types
+// must be registered with gob.Register like for GobEncode.
+func (m *SyncMap) WriteTo(w io.Writer) (int64, error) {
+	var (
+		written int64
+		buf     bytes.Buffer
+	)
+	for _, shard := range m.GetShards() {
+		for _, item := range shard.snapshot() {
+			buf.Reset()
+			if err := gob.NewEncoder(&buf).Encode(&item.Value); err != nil {
+				return written, err
+			}
+			n, err := writeFrame(w, []byte(item.Key))
+			written += n
+			if err != nil {
+				return written, err
+			}
+			n, err = writeFrame(w, buf.Bytes())
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// ReadFrom adds the entries written by WriteTo until r reaches EOF.
+func (m *SyncMap) ReadFrom(r io.Reader) (int64, error) {
+	var read int64
+	for {
+		key, n, err := readFrame(r)
+		read += n
+		if err == io.EOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, err
+		}
+
+		data, n, err := readFrame(r)
+		read += n
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return read, err
+		}
+
+		var value interface{}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+			return read, err
+		}
+		m.Set(string(key), value)
+	}
+}
+
+func writeFrame(w io.Writer, data []byte) (int64, error) {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	n, err := w.Write(size[:])
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(data)
+	return int64(n + m), err
+}
+
+// readFrame returns io.EOF only when r ends cleanly before a frame starts.
+func readFrame(r io.Reader) ([]byte, int64, error) {
+	var size [4]byte
+	n, err := io.ReadFull(r, size[:])
+	if err != nil {
+		if err == io.ErrUnexpectedEOF || n > 0 {
+			return nil, int64(n), io.ErrUnexpectedEOF
+		}
+		return nil, int64(n), err
+	}
+
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxFrameSize {
+		return nil, int64(n), errFrameTooLarge
+	}
+	data := make([]byte, length)
+	m, err := io.ReadFull(r, data)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, int64(n + m), err
+}
@@ -0,0 +1,52 @@
+package syncmap
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestWriteToReadFromFile(t *testing.T) {
+	m := fill(New(), 10000)
+	m.Set("str", "value")
+
+	path := filepath.Join(t.TempDir(), "map.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := m.WriteTo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != written {
+		t.Fatalf("WriteTo reported %d bytes, file holds %v (%v)", written, info.Size(), err)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	restored := NewWithShard(8)
+	read, err := restored.ReadFrom(f)
+	if err != nil || read != written {
+		t.Fatalf("ReadFrom = %d, %v, want %d bytes", read, err, written)
+	}
+
+	if restored.Size() != m.Size() {
+		t.Fatalf("restored %d entries, want %d", restored.Size(), m.Size())
+	}
+	for _, i := range []int{0, 1, 4242, 9999} {
+		if value, ok := restored.Get(strconv.Itoa(i)); !ok || value != i {
+			t.Fatalf("restored %d = %v, %v", i, value, ok)
+		}
+	}
+	if value, _ := restored.Get("str"); value != "value" {
+		t.Fatalf("restored str = %v", value)
+	}
+}
@@ -1,18 +1,78 @@
 package syncmap
 
 import (
+	"container/list"
+	"math"
 	"math/rand"
+	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	defaultShardCount int = 128
+	minAutoShardCount int = 16
+	maxAutoShardCount int = 4096
+	// past wideHashShardCount shards the default hasher switches to fnv64,
+	// the low bits of fnv32 spread poorly over that many shards.
+	wideHashShardCount int = 4096
 )
 
 type ShardMap struct {
 	items map[string]interface{}
 	sync.RWMutex
+	// peak is the largest len(items) since the items map was allocated,
+	// ShrinkIfSparse compares against it.
+	peak int
+
+	// expires holds unix nano deadlines of keys set with a ttl, it is nil
+	// until the shard sees its first SetWithTTL.
+	expires map[string]int64
+
+	// versions holds the version of keys stored by SetIfNewer, a plain set
+	// drops it like it drops a ttl.
+	versions map[string]uint64
+
+	hooks   *shardHooks
+	evicted []Item
+	// quiet suppresses change events while Resize fills a new shard.
+	quiet bool
+
+	// lru orders keys from most to least recently used when the shard is
+	// bounded by maxEntries.
+	lru        *list.List
+	lruIndex   map[string]*list.Element
+	maxEntries int
+
+	// sizes tracks the estimated footprint of each key for maps bounded by
+	// NewWithMaxBytes, bytes is their sum and is also counted in budget.
+	sizer  func(value interface{}) int64
+	sizes  map[string]int64
+	bytes  int64
+	budget *byteBudget
+
+	// calls tracks the GetOrCompute calls in flight for missing keys.
+	calls map[string]*computeCall
+
+	// meta records creation and access statistics per key for maps built
+	// by NewWithMetadata.
+	meta map[string]*entryMeta
+
+	// view serves lock free reads for the maps built by NewCOW.
+	view *cowView
+
+	// order keeps keys in insertion order for maps built by NewOrdered.
+	order      *list.List
+	orderIndex map[string]*list.Element
+
+	// retired is set by Resize under the write lock once the entries moved
+	// to the new shards, operations that find it set go through the new
+	// shard table instead.
+	retired atomic.Bool
 }
 
 func (sd *ShardMap) GetItems() map[string]interface{} {
@@ -21,117 +81,827 @@ func (sd *ShardMap) GetItems() map[string]interface{} {
 
 func (sd *ShardMap) GetNotLock(key string) (interface{}, bool) {
 	v, ok := sd.items[key]
+	if ok && sd.expiredNotLock(key, time.Now().UnixNano()) {
+		return nil, false
+	}
 	return v, ok
 }
 
 func (sd *ShardMap) SetNotLock(key string, val interface{}) {
+	if !sd.quiet {
+		sd.hooks.mutated()
+		if sd.hooks.watching() {
+			sd.hooks.publish(ChangeEvent{Key: key, Op: OpSet, Value: val, OldValue: sd.items[key]})
+		}
+	}
 	sd.items[key] = val
+	if len(sd.items) > sd.peak {
+		sd.peak = len(sd.items)
+	}
+	if sd.expires != nil {
+		delete(sd.expires, key)
+	}
+	if sd.versions != nil {
+		delete(sd.versions, key)
+	}
+	if sd.view != nil {
+		sd.view.invalidate()
+	}
+	if sd.meta != nil {
+		if _, ok := sd.meta[key]; !ok {
+			sd.meta[key] = newEntryMeta()
+		}
+	}
+	if sd.order != nil {
+		if _, ok := sd.orderIndex[key]; !ok {
+			sd.orderIndex[key] = sd.order.PushBack(key)
+		}
+	}
+	if sd.sizes != nil {
+		size := sd.sizeOf(key, val)
+		sd.addBytes(size - sd.sizes[key])
+		sd.sizes[key] = size
+	}
+	if sd.lru != nil {
+		sd.touchNotLock(key)
+		sd.evictOverflowNotLock()
+	}
 }
 
 func (sd *ShardMap) DeleteNotLock(key string) {
+	if v, ok := sd.takeNotLock(key); ok {
+		sd.evictNotLock(key, v)
+	}
+}
+
+// takeNotLock removes key without reporting it as evicted, the caller gets
+// the value back and owns it from then on.
+func (sd *ShardMap) takeNotLock(key string) (interface{}, bool) {
+	v, ok := sd.items[key]
+	if !ok {
+		return nil, false
+	}
+	sd.hooks.mutated()
+	if sd.hooks.watching() {
+		sd.hooks.publish(ChangeEvent{Key: key, Op: OpDelete, OldValue: v})
+	}
 	delete(sd.items, key)
+	if sd.expires != nil {
+		delete(sd.expires, key)
+	}
+	if sd.versions != nil {
+		delete(sd.versions, key)
+	}
+	if sd.view != nil {
+		sd.view.invalidate()
+	}
+	if sd.lru != nil {
+		sd.lru.Remove(sd.lruIndex[key])
+		delete(sd.lruIndex, key)
+	}
+	if sd.meta != nil {
+		delete(sd.meta, key)
+	}
+	if sd.order != nil {
+		sd.order.Remove(sd.orderIndex[key])
+		delete(sd.orderIndex, key)
+	}
+	if sd.sizes != nil {
+		sd.addBytes(-sd.sizes[key])
+		delete(sd.sizes, key)
+	}
+	return v, true
 }
 
 func (sd *ShardMap) GetWithLock(key string) (interface{}, bool) {
+	if sd.view != nil {
+		return sd.getFromView(key)
+	}
+	if sd.lru != nil || sd.meta != nil {
+		return sd.getAndTouch(key)
+	}
+
 	sd.RLock()
 	v, ok := sd.items[key]
+	expired := ok && sd.expiredNotLock(key, time.Now().UnixNano())
 	sd.RUnlock()
+	if expired {
+		sd.deleteExpired(key)
+		return nil, false
+	}
 	return v, ok
 }
 
 func (sd *ShardMap) SetWithLock(key string, val interface{}) {
 	sd.Lock()
-	sd.items[key] = val
+	sd.SetNotLock(key, val)
 	sd.Unlock()
 }
 
 func (sd *ShardMap) DeleteWithLock(key string) {
 	sd.Lock()
-	delete(sd.items, key)
+	sd.DeleteNotLock(key)
 	sd.Unlock()
 }
 
+// Range calls fn for every entry of the shard under its read lock until fn
+// returns false, fn must not write to the map.
+func (sd *ShardMap) Range(fn func(key string, value interface{}) bool) {
+	sd.RLock()
+	defer sd.RUnlock()
+
+	for key, value := range sd.items {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+func (sd *ShardMap) flushNotLock() int {
+	size := len(sd.items)
+	sd.hooks.mutated()
+	if sd.hooks.evicting() {
+		for key, value := range sd.items {
+			sd.evicted = append(sd.evicted, Item{key, value})
+		}
+	}
+	if sd.hooks.watching() {
+		for key, value := range sd.items {
+			sd.hooks.publish(ChangeEvent{Key: key, Op: OpDelete, OldValue: value})
+		}
+	}
+	sd.items = make(map[string]interface{})
+	sd.peak = 0
+	sd.expires = nil
+	sd.versions = nil
+	if sd.view != nil {
+		sd.view.invalidate()
+	}
+	if sd.lru != nil {
+		sd.lru.Init()
+		sd.lruIndex = make(map[string]*list.Element)
+	}
+	if sd.meta != nil {
+		sd.meta = make(map[string]*entryMeta)
+	}
+	if sd.order != nil {
+		sd.order.Init()
+		sd.orderIndex = make(map[string]*list.Element)
+	}
+	if sd.sizes != nil {
+		sd.sizes = make(map[string]int64)
+		sd.addBytes(-sd.bytes)
+	}
+	return size
+}
+
 type SyncMap struct {
+	// mu guards shards and shardCount, only Resize takes it for writing.
+	// Single key operations load table instead, they only wait on mu when
+	// a Resize retired their shard.
+	mu         sync.RWMutex
 	shardCount int
+	requested  int
 	shards     []*ShardMap
+	table      atomic.Pointer[shardTable]
+	hooks      *shardHooks
+	hasher     func(string) uint32
+	autoHash   bool
+	placement  PlacementFunc
+	maxEntries int
+	metadata   bool
+	maxBytes   int64
+	sizer      func(value interface{}) int64
+	cow        bool
+	capacity   int
+	ordered    bool
+	popCursor  atomic.Uint32
+	metrics    *mapMetrics
+
+	stopJanitor chan struct{}
+	closeOnce   sync.Once
 }
 
 func New() *SyncMap {
 	return NewWithShard(defaultShardCount)
 }
 
+// NewAuto sizes the map from GOMAXPROCS, see autoShardCount.
+func NewAuto() *SyncMap {
+	return NewWithShard(autoShardCount(runtime.GOMAXPROCS(0)))
+}
+
+// autoShardCount gives every proc about four shards to spread contention,
+// bounded so small boxes don't waste memory and big ones don't go wild.
+func autoShardCount(procs int) int {
+	n := NextPow2(4 * procs)
+	if n < minAutoShardCount {
+		n = minAutoShardCount
+	}
+	if n > maxAutoShardCount {
+		n = maxAutoShardCount
+	}
+	return n
+}
+
+// NewWithShard rounds shardCount up to the next power of two, locate relies
+// on it to pick a shard with a mask instead of a modulo.
 func NewWithShard(shardCount int) *SyncMap {
-	if shardCount == 0 {
-		shardCount = defaultShardCount
+	return NewWithHasher(shardCount, nil)
+}
+
+// NewWithCapacity presizes every shard for its share of totalHint entries,
+// which saves the rehashing of growing maps when bulk loading.
+func NewWithCapacity(shardCount, totalHint int) *SyncMap {
+	m := new(SyncMap)
+	if totalHint > 0 {
+		m.capacity = totalHint
 	}
+	m.init(shardCount, nil)
+	return m
+}
 
+// FromMap returns a map presized for and loaded with the entries of src
+// through MSet.
+func FromMap(shardCount int, src map[string]interface{}) *SyncMap {
+	m := NewWithCapacity(shardCount, len(src))
+	m.MSet(src)
+	return m
+}
+
+// NewWithHasher is NewWithShard with a custom hash used to pick the shard of
+// a key, nil falls back to fnv32, or to fnv64 beyond 4096 shards.
+func NewWithHasher(shardCount int, hasher func(string) uint32) *SyncMap {
 	m := new(SyncMap)
-	m.shardCount = shardCount
+	m.init(shardCount, hasher)
+	return m
+}
+
+func (m *SyncMap) init(shardCount int, hasher func(string) uint32) {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	m.requested = shardCount
+	m.shardCount = m.roundShardCount(shardCount)
+	if hasher == nil {
+		m.autoHash = true
+		hasher = defaultHasher(m.shardCount)
+	}
+	m.hasher = hasher
+	m.hooks = new(shardHooks)
+	if m.maxBytes > 0 {
+		m.hooks.budget = &byteBudget{max: m.maxBytes, m: m}
+	}
 	m.shards = make([]*ShardMap, m.shardCount)
 	for i, _ := range m.shards {
-		m.shards[i] = &ShardMap{items: make(map[string]interface{})}
+		m.shards[i] = m.newShard(i)
 	}
-	return m
+	m.table.Store(&shardTable{m.shards, m.hasher})
+}
+
+func (m *SyncMap) newShard(index int) *ShardMap {
+	sd := &ShardMap{items: make(map[string]interface{}, m.capacity/m.shardCount), hooks: m.hooks}
+	if m.maxEntries > 0 {
+		// the first maxEntries%shardCount shards take one more entry, so the
+		// shares add up to maxEntries exactly.
+		share := m.maxEntries / m.shardCount
+		if index < m.maxEntries%m.shardCount {
+			share++
+		}
+		sd.initLRU(share)
+	}
+	if m.maxBytes > 0 {
+		sd.initLRU(math.MaxInt)
+		sd.sizer = m.sizer
+		sd.sizes = make(map[string]int64)
+		sd.budget = m.hooks.budget
+	}
+	if m.metadata {
+		sd.meta = make(map[string]*entryMeta)
+	}
+	if m.cow {
+		sd.view = new(cowView)
+	}
+	if m.ordered {
+		sd.order = list.New()
+		sd.orderIndex = make(map[string]*list.Element)
+	}
+	return sd
+}
+
+// shardTable is a shard set with the hasher routing keys to it, Resize
+// swaps it as a whole so single key operations can load it without m.mu.
+type shardTable struct {
+	shards []*ShardMap
+	hasher func(string) uint32
+}
+
+// route returns the shard of key in the current table, it may have been
+// retired by a Resize by the time the caller locks it.
+func (m *SyncMap) route(key string) *ShardMap {
+	t := m.table.Load()
+	return t.shards[m.pick(t.hasher(key), len(t.shards))]
 }
 
+// Locate returns the shard of key, waiting for a Resize that retired it to
+// publish the new shards.
 func (m *SyncMap) Locate(key string) *ShardMap {
-	return m.locate(key)
+	for {
+		shard := m.route(key)
+		if !shard.retired.Load() {
+			return shard
+		}
+		m.awaitResize()
+	}
+}
+
+// ShardIndex is the position in GetShards of the shard holding key.
+func (m *SyncMap) ShardIndex(key string) int {
+	t := m.table.Load()
+	return m.pick(t.hasher(key), len(t.shards))
+}
+
+// lockKey returns the write locked shard of key. The shard is looked up
+// again when a Resize retired it before the lock was acquired.
+func (m *SyncMap) lockKey(key string) *ShardMap {
+	for {
+		shard := m.route(key)
+		shard.Lock()
+		if !shard.retired.Load() {
+			return shard
+		}
+		shard.RWMutex.Unlock()
+		m.awaitResize()
+	}
+}
+
+// rlockKey is lockKey taking the read lock.
+func (m *SyncMap) rlockKey(key string) *ShardMap {
+	for {
+		shard := m.route(key)
+		shard.RLock()
+		if !shard.retired.Load() {
+			return shard
+		}
+		shard.RUnlock()
+		m.awaitResize()
+	}
+}
+
+// awaitResize returns once the Resize running, if any, stored the new shard
+// table.
+func (m *SyncMap) awaitResize() {
+	m.mu.RLock()
+	m.mu.RUnlock()
 }
 
 func (m *SyncMap) locate(key string) *ShardMap {
-	return m.shards[fnv32(key)&uint32((m.shardCount-1))]
+	return m.shards[m.locateIndex(key)]
+}
+
+func (m *SyncMap) locateIndex(key string) int {
+	return m.pick(m.hasher(key), m.shardCount)
+}
+
+// pick maps the hash of a key to the index of its shard among shardCount.
+func (m *SyncMap) pick(hash uint32, shardCount int) int {
+	if m.placement != nil {
+		return m.placement(hash, shardCount)
+	}
+	return int(hash & uint32((shardCount - 1)))
+}
+
+// roundShardCount is the shard count used for a request of n, a power of two
+// for the mask of locateIndex unless a placement picks the shard. An LRU map
+// is halved down to at most maxEntries shards so every shard holds one entry.
+func (m *SyncMap) roundShardCount(n int) int {
+	if m.placement == nil {
+		n = NextPow2(n)
+	}
+	for m.maxEntries > 0 && n > m.maxEntries {
+		n >>= 1
+	}
+	return n
+}
+
+// emptyLike returns an empty map built with the options of m, so it routes
+// keys exactly like m. The caller holds m.mu.
+func (m *SyncMap) emptyLike() *SyncMap {
+	like := new(SyncMap)
+	like.placement = m.placement
+	like.maxEntries = m.maxEntries
+	like.metadata = m.metadata
+	like.maxBytes = m.maxBytes
+	like.sizer = m.sizer
+	like.cow = m.cow
+	like.capacity = m.capacity
+	like.ordered = m.ordered
+	if m.metrics != nil {
+		like.metrics = new(mapMetrics)
+	}
+	hasher := m.hasher
+	if m.autoHash {
+		hasher = nil
+	}
+	like.init(m.shardCount, hasher)
+	if m.hooks.watch != nil {
+		like.hooks.watch = new(watchers)
+	}
+	return like
 }
 
+func (m *SyncMap) ShardCount() int {
+	return len(m.table.Load().shards)
+}
+
+// RequestedShardCount is the shard count passed to the constructor or the
+// last Resize, before the rounding of ShardCount. A count below one stands
+// for the default of 128.
+func (m *SyncMap) RequestedShardCount() int {
+	m.mu.RLock()
+	n := m.requested
+	m.mu.RUnlock()
+	return n
+}
+
+const defaultJoinSep = "-"
+
 func (m *SyncMap) GetJoinKey(key ...string) (value interface{}, ok bool) {
-	return m.Get(strings.Join(key[:], "-"))
+	return m.GetJoinKeyWith(defaultJoinSep, key...)
+}
+
+// GetJoinKeyWith looks up the key made of keys joined by sep, it reports a
+// miss when no key part is given.
+func (m *SyncMap) GetJoinKeyWith(sep string, keys ...string) (value interface{}, ok bool) {
+	if len(keys) == 0 {
+		return nil, false
+	}
+	return m.Get(strings.Join(keys, sep))
+}
+
+func (m *SyncMap) SetJoinKey(value interface{}, key ...string) {
+	m.SetJoinKeyWith(defaultJoinSep, value, key...)
+}
+
+// SetJoinKeyWith stores value under keys joined by sep, it does nothing when
+// no key part is given.
+func (m *SyncMap) SetJoinKeyWith(sep string, value interface{}, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	m.Set(strings.Join(keys, sep), value)
 }
 
 func (m *SyncMap) GetShards() []*ShardMap {
-	return m.shards
+	return m.table.Load().shards
 }
 
 func (m *SyncMap) Get(key string) (value interface{}, ok bool) {
-	shard := m.locate(key)
-	return shard.GetWithLock(key)
+	for {
+		shard := m.Locate(key)
+		value, ok = shard.GetWithLock(key)
+		// a shard retired during the read may have missed later writes.
+		if !shard.retired.Load() {
+			break
+		}
+	}
+	if m.metrics != nil {
+		m.metrics.get(ok)
+	}
+	return value, ok
+}
+
+func (m *SyncMap) GetOrDefault(key string, def interface{}) interface{} {
+	if value, ok := m.Get(key); ok {
+		return value
+	}
+	return def
 }
 
 func (m *SyncMap) Set(key string, value interface{}) {
-	shard := m.locate(key)
-	shard.SetWithLock(key, value)
+	shard := m.lockKey(key)
+	shard.SetNotLock(key, value)
+	shard.Unlock()
+	if m.metrics != nil {
+		m.metrics.sets.Add(1)
+	}
 }
 
 func (m *SyncMap) Delete(key string) {
-	shard := m.locate(key)
-	shard.DeleteWithLock(key)
+	shard := m.lockKey(key)
+	shard.DeleteNotLock(key)
+	shard.Unlock()
+	if m.metrics != nil {
+		m.metrics.deletes.Add(1)
+	}
 }
 
-func (m *SyncMap) Pop() (string, interface{}) {
-	if m.Size() == 0 {
-		panic("syncmap: map is empty")
+// DeleteAndReturn is Delete also returning the removed value, ok is false when
+// key was missing. Unlike GetAndDelete, which hands the value over to the
+// caller, the removal is reported to OnEvicted like any Delete.
+func (m *SyncMap) DeleteAndReturn(key string) (interface{}, bool) {
+	shard := m.lockKey(key)
+	value, ok := shard.GetNotLock(key)
+	shard.DeleteNotLock(key)
+	shard.Unlock()
+	if m.metrics != nil {
+		m.metrics.deletes.Add(1)
 	}
+	return value, ok
+}
 
-	var (
-		key   string
-		value interface{}
-		found = false
-		n     = int(m.shardCount)
-	)
+// SetIfAbsent stores value only when key is missing and reports whether it
+// did so.
+func (m *SyncMap) SetIfAbsent(key string, value interface{}) bool {
+	shard := m.lockKey(key)
+	_, ok := shard.GetNotLock(key)
+	if !ok {
+		shard.SetNotLock(key, value)
+	}
+	shard.Unlock()
+	return !ok
+}
 
-	for !found {
-		idx := rand.Intn(n)
-		shard := m.shards[idx]
-		shard.Lock()
-		if len(shard.items) > 0 {
-			found = true
-			for key, value = range shard.items {
+// GetOrSet behaves like sync.Map.LoadOrStore, the resident value wins and
+// loaded reports whether it was already there.
+func (m *SyncMap) GetOrSet(key string, value interface{}) (actual interface{}, loaded bool) {
+	shard := m.lockKey(key)
+	actual, loaded = shard.GetNotLock(key)
+	if !loaded {
+		shard.SetNotLock(key, value)
+		actual = value
+	}
+	shard.Unlock()
+	return actual, loaded
+}
+
+func (m *SyncMap) GetAndDelete(key string) (interface{}, bool) {
+	shard := m.lockKey(key)
+	value, ok := shard.GetNotLock(key)
+	if ok {
+		shard.takeNotLock(key)
+	}
+	shard.Unlock()
+	return value, ok
+}
+
+type UpdateFunc func(old interface{}, exists bool) (value interface{}, keep bool)
+
+// Update runs fn under the shard write lock, the key is stored with the
+// returned value when keep is true and removed otherwise. fn must not call
+// back into the same SyncMap or it will deadlock.
+func (m *SyncMap) Update(key string, fn UpdateFunc) {
+	shard := m.lockKey(key)
+	old, exists := shard.GetNotLock(key)
+	value, keep := fn(old, exists)
+	if keep {
+		shard.SetNotLock(key, value)
+	} else if exists {
+		shard.DeleteNotLock(key)
+	}
+	shard.Unlock()
+}
+
+// Upsert stores and returns insert() when key is missing or update(old) when
+// it is present, both run under the shard write lock and must not call back
+// into the map.
+func (m *SyncMap) Upsert(key string, insert func() interface{}, update func(old interface{}) interface{}) interface{} {
+	shard := m.lockKey(key)
+	var value interface{}
+	if old, ok := shard.GetNotLock(key); ok {
+		value = update(old)
+	} else {
+		value = insert()
+	}
+	shard.SetNotLock(key, value)
+	shard.Unlock()
+	return value
+}
+
+// Add increments the int64 stored under key by delta and returns the new
+// total, a missing key counts as zero. Add panics if the resident value is
+// not an int64.
+func (m *SyncMap) Add(key string, delta int64) int64 {
+	shard := m.lockKey(key)
+	total := delta
+	if old, ok := shard.GetNotLock(key); ok {
+		n, isInt := old.(int64)
+		if !isInt {
+			shard.Unlock()
+			panic("syncmap: Add on a non int64 value")
+		}
+		total += n
+	}
+	shard.SetNotLock(key, total)
+	shard.Unlock()
+	return total
+}
+
+// AddAndPrune is Add for reference counts, the key is deleted once its total
+// drops to zero or below, removed reports whether an entry was deleted.
+func (m *SyncMap) AddAndPrune(key string, delta int64) (newVal int64, removed bool) {
+	shard := m.lockKey(key)
+	newVal = delta
+	old, exists := shard.GetNotLock(key)
+	if exists {
+		n, isInt := old.(int64)
+		if !isInt {
+			shard.Unlock()
+			panic("syncmap: AddAndPrune on a non int64 value")
+		}
+		newVal += n
+	}
+	if newVal <= 0 {
+		shard.DeleteNotLock(key)
+		removed = exists
+	} else {
+		shard.SetNotLock(key, newVal)
+	}
+	shard.Unlock()
+	return newVal, removed
+}
+
+// AddFloat is Add for float64 values, it panics if the resident value is not
+// a float64.
+func (m *SyncMap) AddFloat(key string, delta float64) float64 {
+	shard := m.lockKey(key)
+	total := delta
+	if old, ok := shard.GetNotLock(key); ok {
+		f, isFloat := old.(float64)
+		if !isFloat {
+			shard.Unlock()
+			panic("syncmap: AddFloat on a non float64 value")
+		}
+		total += f
+	}
+	shard.SetNotLock(key, total)
+	shard.Unlock()
+	return total
+}
+
+// CompareAndSwap stores new only if the current value of key equals old.
+// Values are compared with == when comparable and with reflect.DeepEqual
+// otherwise, a missing key never matches.
+func (m *SyncMap) CompareAndSwap(key string, old, new interface{}) bool {
+	shard := m.lockKey(key)
+	cur, ok := shard.GetNotLock(key)
+	swapped := ok && valuesEqual(cur, old)
+	if swapped {
+		shard.SetNotLock(key, new)
+	}
+	shard.Unlock()
+	return swapped
+}
+
+// CompareAndDelete removes key only if its current value equals old, using the
+// same comparison as CompareAndSwap.
+func (m *SyncMap) CompareAndDelete(key string, old interface{}) bool {
+	shard := m.lockKey(key)
+	cur, ok := shard.GetNotLock(key)
+	deleted := ok && valuesEqual(cur, old)
+	if deleted {
+		shard.DeleteNotLock(key)
+	}
+	shard.Unlock()
+	return deleted
+}
+
+func (m *SyncMap) Swap(key string, value interface{}) (previous interface{}, loaded bool) {
+	shard := m.lockKey(key)
+	previous, loaded = shard.GetNotLock(key)
+	shard.SetNotLock(key, value)
+	shard.Unlock()
+	return previous, loaded
+}
+
+// Replace stores value only when key is already present and reports whether
+// it did so, the counterpart of SetIfAbsent.
+func (m *SyncMap) Replace(key string, value interface{}) bool {
+	shard := m.lockKey(key)
+	_, ok := shard.GetNotLock(key)
+	if ok {
+		shard.SetNotLock(key, value)
+	}
+	shard.Unlock()
+	return ok
+}
+
+// Pop removes a random entry, ok is false when the map is empty. Shards are
+// visited once from a random offset by a random step coprime to the shard
+// count, so concurrent pops can't spin and no shard is favoured by its
+// position. Expired entries met on the way are dropped and reported to
+// OnEvicted.
+func (m *SyncMap) Pop() (key string, value interface{}, ok bool) {
+	return m.popOne(randomWalk)
+}
+
+// PopFrom is Pop draining the shard at preferShard first and moving on to the
+// following shards once it is empty, so every worker of a work stealing queue
+// can favour its own shard. preferShard is taken modulo ShardCount.
+func (m *SyncMap) PopFrom(preferShard int) (key string, value interface{}, ok bool) {
+	return m.popOne(func(shardCount int) (int, int) {
+		start := preferShard % shardCount
+		if start < 0 {
+			start += shardCount
+		}
+		return start, 1
+	})
+}
+
+func (m *SyncMap) popOne(walk walkFunc) (key string, value interface{}, ok bool) {
+	var buf [1]Item
+	items := m.popItems(walk, 1, buf[:0])
+	if len(items) == 0 {
+		return "", nil, false
+	}
+	return items[0].Key, items[0].Value, true
+}
+
+// PopN removes and returns up to n entries, locking each shard at most once.
+// Expired entries are dropped like in Pop.
+func (m *SyncMap) PopN(n int) []Item {
+	if n <= 0 {
+		return nil
+	}
+
+	size := m.Size()
+	if size > n {
+		size = n
+	}
+	return m.popItems(randomWalk, n, make([]Item, 0, size))
+}
+
+// popItems appends up to n live entries to items, removing them from the
+// shards in the order walk gives.
+func (m *SyncMap) popItems(walk walkFunc, n int, items []Item) []Item {
+	now := time.Now().UnixNano()
+	m.walkShards(walk, func(shard *ShardMap) bool {
+		for key, value := range shard.items {
+			if len(items) == n {
 				break
 			}
-			delete(shard.items, key)
+			if shard.expiredNotLock(key, now) {
+				shard.DeleteNotLock(key)
+				continue
+			}
+			shard.takeNotLock(key)
+			items = append(items, Item{key, value})
+		}
+		return len(items) < n
+	})
+	return items
+}
+
+// walkFunc returns the index of the first shard to visit and the step to the
+// next one, which must be coprime to shardCount.
+type walkFunc func(shardCount int) (start, step int)
+
+func randomWalk(shardCount int) (start, step int) {
+	step = 1
+	if shardCount > 2 {
+		step = 1 + rand.Intn(shardCount-1)
+		for gcd(step, shardCount) != 1 {
+			step = 1 + rand.Intn(shardCount-1)
 		}
-		shard.Unlock()
 	}
+	return rand.Intn(shardCount), step
+}
 
-	return key, value
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// walkShards write locks the shards one at a time in the order walk gives
+// and calls fn with each until fn returns false or every shard was visited.
+// It starts over on the new shards when it meets one retired by a Resize,
+// and reports the evicted entries once the last lock is released.
+func (m *SyncMap) walkShards(walk walkFunc, fn func(shard *ShardMap) bool) {
+	var evicted []Item
+	for restart := true; restart; {
+		restart = false
+		shards := m.GetShards()
+		idx, step := walk(len(shards))
+		for range shards {
+			shard := shards[idx]
+			shard.Lock()
+			if shard.retired.Load() {
+				shard.RWMutex.Unlock()
+				m.awaitResize()
+				restart = true
+				break
+			}
+			more := fn(shard)
+			evicted = shard.unlockDeferred(evicted)
+			if !more {
+				break
+			}
+			idx = (idx + step) % len(shards)
+		}
+	}
+	m.hooks.notify(evicted)
 }
 
 func (m *SyncMap) Has(key string) bool {
@@ -141,7 +911,7 @@ func (m *SyncMap) Has(key string) bool {
 
 func (m *SyncMap) Size() int {
 	size := 0
-	for _, shard := range m.shards {
+	for _, shard := range m.GetShards() {
 		shard.RLock()
 		size += len(shard.items)
 		shard.RUnlock()
@@ -149,22 +919,182 @@ func (m *SyncMap) Size() int {
 	return size
 }
 
+// Len is an alias of Size.
+func (m *SyncMap) Len() int {
+	return m.Size()
+}
+
+// Version is a counter bumped by every set, removal and flush of the map, an
+// unchanged Version means the map was not modified in between. Reads that
+// drop an expired entry count as a removal.
+func (m *SyncMap) Version() uint64 {
+	return m.hooks.version.Load()
+}
+
+func (m *SyncMap) IsEmpty() bool {
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		n := len(shard.items)
+		shard.RUnlock()
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (m *SyncMap) Flush() int {
-	size := 0
+	var (
+		size    int
+		evicted []Item
+	)
+	m.mu.RLock()
 	for _, shard := range m.shards {
 		shard.Lock()
-		size += len(shard.items)
-		shard.items = make(map[string]interface{})
-		shard.Unlock()
+		size += shard.flushNotLock()
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+	return size
+}
+
+// FlushShards is Flush limited to the shards at indices, it returns how many
+// entries were removed and panics before clearing anything when an index is
+// outside [0, ShardCount).
+func (m *SyncMap) FlushShards(indices []int) int {
+	var (
+		size    int
+		evicted []Item
+	)
+	indices = uniqueSorted(append([]int(nil), indices...))
+	m.mu.RLock()
+	for _, idx := range indices {
+		if idx < 0 || idx >= m.shardCount {
+			m.mu.RUnlock()
+			panic("syncmap: shard index " + strconv.Itoa(idx) + " out of range")
+		}
 	}
+	for _, idx := range indices {
+		shard := m.shards[idx]
+		shard.Lock()
+		size += shard.flushNotLock()
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
 	return size
 }
 
+// FlushFunc is Flush calling fn once for every cleared entry, after all
+// locks are released so fn may use the map.
+func (m *SyncMap) FlushFunc(fn func(key string, value interface{})) int {
+	var (
+		size    int
+		evicted []Item
+		cleared = make([]map[string]interface{}, 0, m.ShardCount())
+	)
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		shard.Lock()
+		cleared = append(cleared, shard.items)
+		size += shard.flushNotLock()
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+
+	for _, items := range cleared {
+		for key, value := range items {
+			fn(key, value)
+		}
+	}
+	return size
+}
+
+func (m *SyncMap) Keys() []string {
+	keys := make([]string, 0, m.Size())
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key := range shard.items {
+			keys = append(keys, key)
+		}
+		shard.RUnlock()
+	}
+	return keys
+}
+
+// Values snapshots each shard in turn, it is not an atomic view of the whole
+// map when writers run concurrently.
+func (m *SyncMap) Values() []interface{} {
+	values := make([]interface{}, 0, m.Size())
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for _, value := range shard.items {
+			values = append(values, value)
+		}
+		shard.RUnlock()
+	}
+	return values
+}
+
+// Items copies every entry into a fresh map, changes made to the returned map
+// are not visible to the SyncMap.
+func (m *SyncMap) Items() map[string]interface{} {
+	items := make(map[string]interface{}, m.Size())
+	for _, shard := range m.GetShards() {
+		shard.RLock()
+		for key, value := range shard.items {
+			items[key] = value
+		}
+		shard.RUnlock()
+	}
+	return items
+}
+
+// ShardSnapshot is Items limited to the shard at index, which must be below
+// ShardCount, so an export can copy the shards from separate goroutines.
+func (m *SyncMap) ShardSnapshot(index int) map[string]interface{} {
+	return m.GetShards()[index].copyItems()
+}
+
+// MapShards calls fn serially with the index and a copy of every shard and
+// returns the results in shard order, fn runs without any lock held.
+func (m *SyncMap) MapShards(fn func(shardIndex int, items map[string]interface{}) interface{}) []interface{} {
+	shards := m.GetShards()
+	results := make([]interface{}, len(shards))
+	for i, shard := range shards {
+		results[i] = fn(i, shard.copyItems())
+	}
+	return results
+}
+
+// GetShardsSnapshot is ShardSnapshot for every shard, in the order of
+// GetShards, each copied under its own read lock.
+func (m *SyncMap) GetShardsSnapshot() []map[string]interface{} {
+	shards := m.GetShards()
+	snapshots := make([]map[string]interface{}, len(shards))
+	for i, shard := range shards {
+		snapshots[i] = shard.copyItems()
+	}
+	return snapshots
+}
+
+func (sd *ShardMap) copyItems() map[string]interface{} {
+	sd.RLock()
+	items := make(map[string]interface{}, len(sd.items))
+	for key, value := range sd.items {
+		items[key] = value
+	}
+	sd.RUnlock()
+	return items
+}
+
 type IterKeyWithBreakFunc func(key string) bool
 
 func (m *SyncMap) EachKeyWithBreak(iter IterKeyWithBreakFunc) {
 	stop := false
-	for _, shard := range m.shards {
+	for _, shard := range m.GetShards() {
 		shard.RLock()
 		for key, _ := range shard.items {
 			if !iter(key) {
@@ -179,6 +1109,15 @@ func (m *SyncMap) EachKeyWithBreak(iter IterKeyWithBreakFunc) {
 	}
 }
 
+type IterKeyFunc func(key string)
+
+func (m *SyncMap) EachKey(iter IterKeyFunc) {
+	m.EachKeyWithBreak(func(key string) bool {
+		iter(key)
+		return true
+	})
+}
+
 type Item struct {
 	Key   string
 	Value interface{}
@@ -188,7 +1127,7 @@ type IterItemWithBreakFunc func(item *Item) bool
 
 func (m *SyncMap) EachItemWithBreak(iter IterItemWithBreakFunc) {
 	stop := false
-	for _, shard := range m.shards {
+	for _, shard := range m.GetShards() {
 		shard.RLock()
 		for key, value := range shard.items {
 			if !iter(&Item{key, value}) {
@@ -213,6 +1152,10 @@ func (m *SyncMap) EachItem(iter IterItemFunc) {
 	m.EachItemWithBreak(f)
 }
 
+// IterItems streams every entry over an unbuffered channel. The producer
+// goroutine blocks until the channel is drained, breaking out of the range
+// loop early leaks it together with a shard read lock, prefer IterItemsCtx
+// and cancel the context instead.
 func (m *SyncMap) IterItems() <-chan Item {
 	ch := make(chan Item)
 	go func() {
@@ -224,6 +1167,23 @@ func (m *SyncMap) IterItems() <-chan Item {
 	return ch
 }
 
+// NextPow2 returns the smallest power of two not below n, the shard count a
+// constructor or Resize ends up with for a request of n.
+func NextPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func defaultHasher(shardCount int) func(string) uint32 {
+	if shardCount > wideHashShardCount {
+		return fnv64
+	}
+	return fnv32
+}
+
 func fnv32(key string) uint32 {
 	hash := uint32(2166136261)
 	const prime32 = uint32(16777619)
@@ -234,3 +1194,28 @@ func fnv32(key string) uint32 {
 	return hash
 }
 
+// fnv64 is 64 bit FNV-1a folded to 32 bits through the splitmix finalizer,
+// so every bit of the shard index depends on the whole key.
+func fnv64(key string) uint32 {
+	hash := uint64(14695981039346656037)
+	const prime64 = uint64(1099511628211)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+	return hashUint64(hash)
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if va.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
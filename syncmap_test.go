@@ -0,0 +1,1043 @@
+package syncmap
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func randomKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.FormatUint(rand.Uint64(), 36)
+	}
+	return keys
+}
+
+func fill(m *SyncMap, n int) *SyncMap {
+	for i := 0; i < n; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+	return m
+}
+
+// parallel runs fn(0..n-1) on n goroutines and waits for all of them.
+func parallel(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestShardDistribution(t *testing.T) {
+	keys := randomKeys(200000)
+	for _, requested := range []int{10, 100, 1000} {
+		m := NewWithShard(requested)
+		if n := m.ShardCount(); n < requested || n&(n-1) != 0 {
+			t.Fatalf("NewWithShard(%d): ShardCount() = %d, want a power of two >= %d", requested, n, requested)
+		}
+		for _, key := range keys {
+			m.Set(key, true)
+		}
+		for i, shard := range m.GetShards() {
+			if len(shard.GetItems()) == 0 {
+				t.Fatalf("NewWithShard(%d): shard %d received no keys", requested, i)
+			}
+		}
+	}
+}
+
+func TestPopConcurrent(t *testing.T) {
+	const items, workers = 10000, 16
+	m := fill(New(), items)
+
+	var (
+		wg     sync.WaitGroup
+		popped atomic.Int64
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, _, ok := m.Pop(); !ok {
+					return
+				}
+				popped.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := popped.Load(); n != items {
+		t.Fatalf("popped %d items, want %d", n, items)
+	}
+	if _, _, ok := New().Pop(); ok {
+		t.Fatal("Pop on an empty map reported an item")
+	}
+}
+
+func TestRandomWalkVisitsEveryShard(t *testing.T) {
+	for n := 1; n <= 70; n++ {
+		for trial := 0; trial < 10; trial++ {
+			start, step := randomWalk(n)
+			seen := make([]bool, n)
+			for i, idx := 0, start; i < n; i, idx = i+1, (idx+step)%n {
+				if seen[idx] {
+					t.Fatalf("walk of %d shards from %d by %d visits %d twice", n, start, step, idx)
+				}
+				seen[idx] = true
+			}
+		}
+	}
+}
+
+func TestPopIsUnbiased(t *testing.T) {
+	// keys[0] is in the first shard and keys[3] in the last, a walk by a
+	// fixed step would reach keys[3] first from three of the four starts.
+	m := NewWithShard(4)
+	shards := m.GetShards()
+	keys := make([]string, 4)
+	for i := 0; keys[0] == "" || keys[3] == ""; i++ {
+		key := strconv.Itoa(i)
+		for _, idx := range []int{0, 3} {
+			if keys[idx] == "" && m.Locate(key) == shards[idx] {
+				keys[idx] = key
+			}
+		}
+	}
+	first := 0
+	for i := 0; i < 4000; i++ {
+		m.Set(keys[0], 0)
+		m.Set(keys[3], 3)
+		if _, value, _ := m.Pop(); value == 0 {
+			first++
+		}
+		m.Flush()
+	}
+	if first < 1600 || first > 2400 {
+		t.Fatalf("Pop took the first shard %d times out of 4000, want about 2000", first)
+	}
+}
+
+func BenchmarkKeys(b *testing.B) {
+	m := fill(New(), 1000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(m.Keys()) != 1000000 {
+			b.Fatal("short key snapshot")
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	m := fill(New(), 1000)
+	values := m.Values()
+	if len(values) != m.Size() {
+		t.Fatalf("len(Values()) = %d, want Size() = %d", len(values), m.Size())
+	}
+	sum := 0
+	for _, v := range values {
+		sum += v.(int)
+	}
+	if want := 999 * 1000 / 2; sum != want {
+		t.Fatalf("sum of values = %d, want %d", sum, want)
+	}
+}
+
+func TestItemsIsDetached(t *testing.T) {
+	m := fill(New(), 100)
+	items := m.Items()
+	if len(items) != 100 {
+		t.Fatalf("len(Items()) = %d, want 100", len(items))
+	}
+	items["1"] = "changed"
+	delete(items, "2")
+	items["new"] = true
+
+	if v, _ := m.Get("1"); v != 1 {
+		t.Fatalf("Get(1) = %v after mutating the snapshot, want 1", v)
+	}
+	if !m.Has("2") || m.Has("new") || m.Size() != 100 {
+		t.Fatal("mutating the snapshot changed the map")
+	}
+}
+
+func TestSetIfAbsentRace(t *testing.T) {
+	m := New()
+	var inserted atomic.Int64
+	parallel(64, func(i int) {
+		if m.SetIfAbsent("once", i) {
+			inserted.Add(1)
+		}
+	})
+	if n := inserted.Load(); n != 1 {
+		t.Fatalf("%d SetIfAbsent calls inserted, want exactly 1", n)
+	}
+	if m.SetIfAbsent("once", -1) {
+		t.Fatal("SetIfAbsent replaced a present key")
+	}
+}
+
+func TestGetOrSetRace(t *testing.T) {
+	m := New()
+	var stored atomic.Int64
+	actuals := make([]interface{}, 64)
+	parallel(64, func(i int) {
+		actual, loaded := m.GetOrSet("key", i)
+		if !loaded {
+			stored.Add(1)
+			if actual != i {
+				t.Errorf("GetOrSet stored %d but returned %v", i, actual)
+			}
+		}
+		actuals[i] = actual
+	})
+	if n := stored.Load(); n != 1 {
+		t.Fatalf("%d GetOrSet calls stored, want exactly 1", n)
+	}
+	resident, _ := m.Get("key")
+	for i, actual := range actuals {
+		if actual != resident {
+			t.Fatalf("caller %d got %v, resident value is %v", i, actual, resident)
+		}
+	}
+}
+
+func TestGetAndDeleteConcurrent(t *testing.T) {
+	const perProducer = 500
+	m := New()
+	var (
+		mu       sync.Mutex
+		inserted = make(map[int]bool)
+		removed  = make(map[int]bool)
+		done     atomic.Bool
+	)
+	consume := func() {
+		v, ok := m.GetAndDelete("slot")
+		if !ok {
+			runtime.Gosched()
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if removed[v.(int)] {
+			t.Errorf("value %v was removed twice", v)
+		}
+		removed[v.(int)] = true
+	}
+
+	var consumers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for !done.Load() {
+				consume()
+			}
+		}()
+	}
+	parallel(4, func(p int) {
+		for i := 0; i < perProducer; i++ {
+			id := p*perProducer + i
+			for !m.SetIfAbsent("slot", id) {
+				runtime.Gosched()
+			}
+			mu.Lock()
+			inserted[id] = true
+			mu.Unlock()
+		}
+	})
+	done.Store(true)
+	consumers.Wait()
+	consume()
+
+	if len(removed) != len(inserted) {
+		t.Fatalf("removed %d values, inserted %d", len(removed), len(inserted))
+	}
+	for id := range inserted {
+		if !removed[id] {
+			t.Fatalf("value %d was inserted but never removed", id)
+		}
+	}
+	if _, ok := m.GetAndDelete("slot"); ok {
+		t.Fatal("GetAndDelete on a missing key reported a value")
+	}
+}
+
+func TestUpdateIncrement(t *testing.T) {
+	m := New()
+	parallel(32, func(int) {
+		for i := 0; i < 100; i++ {
+			m.Update("counter", func(old interface{}, exists bool) (interface{}, bool) {
+				if !exists {
+					return 1, true
+				}
+				return old.(int) + 1, true
+			})
+		}
+	})
+	if v, _ := m.Get("counter"); v != 3200 {
+		t.Fatalf("counter = %v, want 3200", v)
+	}
+
+	m.Update("counter", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	if m.Has("counter") {
+		t.Fatal("Update returning keep=false left the key in place")
+	}
+}
+
+func TestAddConcurrent(t *testing.T) {
+	m := New()
+	parallel(32, func(i int) {
+		for j := 0; j < 100; j++ {
+			m.Add("total", int64(i))
+		}
+	})
+	if v, _ := m.Get("total"); v != int64(31*32/2*100) {
+		t.Fatalf("total = %v, want %d", v, 31*32/2*100)
+	}
+	if n := m.Add("fresh", -3); n != -3 {
+		t.Fatalf("Add on a missing key = %d, want -3", n)
+	}
+}
+
+func TestAddNonInt64Panics(t *testing.T) {
+	m := New()
+	m.Set("word", "x")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Add on a string value did not panic")
+		}
+		if !m.Has("word") {
+			t.Fatal("the failed Add removed the value")
+		}
+		m.Set("word", "y") // the shard lock was released
+	}()
+	m.Add("word", 1)
+}
+
+func TestCustomHasherBalancesPrefixedKeys(t *testing.T) {
+	mix := func(key string) uint32 {
+		h := uint64(0x9e3779b97f4a7c15)
+		for i := 0; i < len(key); i++ {
+			h = (h ^ uint64(key[i])) * 0x100000001b3
+		}
+		return hashUint64(h)
+	}
+	m := NewWithHasher(64, mix)
+	const n = 64000
+	for i := 0; i < n; i++ {
+		m.Set("tenant-6ba7b810-9dad-11d1-80b4-00c04fd430c8:"+strconv.Itoa(i), i)
+	}
+	mean := n / m.ShardCount()
+	for i, shard := range m.GetShards() {
+		if size := len(shard.GetItems()); size < mean/2 || size > 2*mean {
+			t.Fatalf("shard %d holds %d keys, mean is %d", i, size, mean)
+		}
+	}
+	if m.Locate("x") != m.GetShards()[mix("x")&63] {
+		t.Fatal("Locate ignores the hasher")
+	}
+}
+
+func TestPopN(t *testing.T) {
+	m := fill(New(), 100)
+	m.SetWithTTL("expired", -1, time.Nanosecond)
+	var evicted []string
+	m.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+		m.Has(key)
+	})
+	time.Sleep(time.Millisecond)
+
+	items := m.PopN(1000)
+	if len(items) != 100 {
+		t.Fatalf("PopN(1000) returned %d items, want 100", len(items))
+	}
+	for _, item := range items {
+		if item.Key == "expired" {
+			t.Fatal("PopN returned an expired entry")
+		}
+	}
+	if m.Size() != 0 || len(evicted) != 1 || evicted[0] != "expired" {
+		t.Fatalf("map not drained or expiry not reported: %d left, evicted %v", m.Size(), evicted)
+	}
+	if m.PopN(0) != nil || len(m.PopN(5)) != 0 {
+		t.Fatal("PopN on an empty map or with n=0 returned items")
+	}
+}
+
+func TestJoinKey(t *testing.T) {
+	m := New()
+	if _, ok := m.GetJoinKey(); ok {
+		t.Fatal("GetJoinKey() without parts reported a hit")
+	}
+	m.SetJoinKey(1)
+	if m.Size() != 0 {
+		t.Fatal("SetJoinKey without parts stored an entry")
+	}
+
+	m.SetJoinKey("single", "a")
+	if value, ok := m.Get("a"); !ok || value != "single" {
+		t.Fatalf("SetJoinKey with one part stored %v, %v under a", value, ok)
+	}
+
+	m.SetJoinKey("dash", "a", "b-c")
+	m.SetJoinKeyWith("|", "pipe", "a-b", "c")
+	if value, _ := m.GetJoinKey("a", "b-c"); value != "dash" {
+		t.Fatalf("GetJoinKey(a, b-c) = %v, want dash", value)
+	}
+	if value, _ := m.GetJoinKeyWith("|", "a-b", "c"); value != "pipe" {
+		t.Fatalf("GetJoinKeyWith(|, a-b, c) = %v, want pipe", value)
+	}
+	if value, ok := m.Get("a-b|c"); !ok || value != "pipe" {
+		t.Fatal("SetJoinKeyWith did not join the parts with the separator")
+	}
+	if _, ok := m.GetJoinKeyWith("|", "a", "b-c"); ok {
+		t.Fatal("GetJoinKeyWith found the key joined by the default separator")
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	m := NewWithShard(16)
+	if !m.IsEmpty() {
+		t.Fatal("a fresh map is not empty")
+	}
+
+	key, last := 0, m.GetShards()[m.ShardCount()-1]
+	for m.Locate(strconv.Itoa(key)) != last {
+		key++
+	}
+	m.Set(strconv.Itoa(key), nil)
+	if m.IsEmpty() {
+		t.Fatal("IsEmpty with one entry in the last shard")
+	}
+	m.Delete(strconv.Itoa(key))
+	if !m.IsEmpty() {
+		t.Fatal("IsEmpty after removing the only entry")
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	m := New()
+	m.Set("owner", 0)
+	var won int64
+	parallel(32, func(i int) {
+		if m.CompareAndSwap("owner", 0, i+1) {
+			atomic.AddInt64(&won, 1)
+		}
+	})
+	if won != 1 {
+		t.Fatalf("%d goroutines swapped the same old value, want 1", won)
+	}
+
+	const workers, rounds = 8, 200
+	m.Set("n", 0)
+	var swaps int64
+	parallel(workers, func(int) {
+		for done := 0; done < rounds; {
+			cur, _ := m.Get("n")
+			if m.CompareAndSwap("n", cur, cur.(int)+1) {
+				atomic.AddInt64(&swaps, 1)
+				done++
+			}
+		}
+	})
+	if value, _ := m.Get("n"); swaps != workers*rounds || value != workers*rounds {
+		t.Fatalf("%d successful swaps, counter %v, want %d", swaps, value, workers*rounds)
+	}
+
+	m.Set("slice", []int{1, 2})
+	if !m.CompareAndSwap("slice", []int{1, 2}, []int{3}) || m.CompareAndSwap("missing", nil, 1) {
+		t.Fatal("CompareAndSwap compared a non-comparable value or matched a missing key")
+	}
+}
+
+func TestCompareAndDeleteAfterConcurrentChange(t *testing.T) {
+	m := New()
+	m.Set("lease", "v1")
+	seen, _ := m.Get("lease")
+
+	changed := make(chan struct{})
+	go func() {
+		m.Set("lease", "v2")
+		close(changed)
+	}()
+	<-changed
+
+	if m.CompareAndDelete("lease", seen) {
+		t.Fatal("CompareAndDelete removed a value changed by another writer")
+	}
+	if value, ok := m.Get("lease"); !ok || value != "v2" {
+		t.Fatalf("lease = %v, %v, want v2", value, ok)
+	}
+	if !m.CompareAndDelete("lease", "v2") || m.Has("lease") {
+		t.Fatal("CompareAndDelete did not remove the expected value")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	m := New()
+	if previous, loaded := m.Swap("k", 1); loaded || previous != nil {
+		t.Fatalf("Swap on insert = %v, %v", previous, loaded)
+	}
+	if previous, loaded := m.Swap("k", 2); !loaded || previous != 1 {
+		t.Fatalf("Swap on overwrite = %v, %v, want 1, true", previous, loaded)
+	}
+	if value, _ := m.Get("k"); value != 2 {
+		t.Fatalf("Get after Swap = %v, want 2", value)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	m := New()
+	if m.Replace("session", 1) || m.Has("session") {
+		t.Fatal("Replace created an absent key")
+	}
+	m.Set("session", 1)
+	if !m.Replace("session", 2) {
+		t.Fatal("Replace did not replace a present key")
+	}
+	if value, _ := m.Get("session"); value != 2 {
+		t.Fatalf("Get after Replace = %v, want 2", value)
+	}
+}
+
+func TestShardIndexMatchesLocate(t *testing.T) {
+	resized := NewWithShard(8)
+	resized.Resize(100)
+	for _, m := range []*SyncMap{New(), NewWithShard(100), NewConsistent(100), resized} {
+		shards := m.GetShards()
+		for _, key := range randomKeys(1000) {
+			idx := m.ShardIndex(key)
+			if idx < 0 || idx >= len(shards) || shards[idx] != m.Locate(key) {
+				t.Fatalf("ShardIndex(%q) = %d does not point at Locate's shard out of %d", key, idx, len(shards))
+			}
+		}
+	}
+}
+
+// readWriter is the part of the map API measured by benchmarkReads.
+type readWriter interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+}
+
+// stdSyncMap runs benchmarkReads on a plain sync.Map for comparison.
+type stdSyncMap struct {
+	m sync.Map
+}
+
+func (s *stdSyncMap) Get(key string) (interface{}, bool) {
+	return s.m.Load(key)
+}
+
+func (s *stdSyncMap) Set(key string, value interface{}) {
+	s.m.Store(key, value)
+}
+
+// benchmarkReads runs parallel Gets on a map holding 10000 keys with one write
+// per writeEvery reads.
+func benchmarkReads(b *testing.B, m readWriter, writeEvery int) {
+	for i := 0; i < 10000; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := strconv.Itoa(i % 10000)
+			if writeEvery > 0 && i%writeEvery == 0 {
+				m.Set(key, i)
+			} else {
+				m.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkReadMostly(b *testing.B) {
+	b.Run("RWMutex", func(b *testing.B) { benchmarkReads(b, New(), 1000) })
+	b.Run("sync.Map", func(b *testing.B) { benchmarkReads(b, new(stdSyncMap), 1000) })
+	b.Run("COW", func(b *testing.B) { benchmarkReads(b, NewCOW(defaultShardCount), 1000) })
+}
+
+func TestAutoShardCount(t *testing.T) {
+	prev := 0
+	for _, procs := range []int{1, 2, 3, 4, 6, 8, 24, 96, 1000, 100000} {
+		n := autoShardCount(procs)
+		if n&(n-1) != 0 {
+			t.Fatalf("autoShardCount(%d) = %d is not a power of two", procs, n)
+		}
+		if n < minAutoShardCount || n > maxAutoShardCount || n < prev {
+			t.Fatalf("autoShardCount(%d) = %d, previous %d", procs, n, prev)
+		}
+		if 4*procs >= minAutoShardCount && 4*procs <= maxAutoShardCount && n < 4*procs {
+			t.Fatalf("autoShardCount(%d) = %d, want at least four shards per proc", procs, n)
+		}
+		prev = n
+	}
+	if autoShardCount(4) != 16 || autoShardCount(96) != 512 {
+		t.Fatalf("autoShardCount(4) = %d, autoShardCount(96) = %d", autoShardCount(4), autoShardCount(96))
+	}
+	if n := NewAuto().ShardCount(); n != autoShardCount(runtime.GOMAXPROCS(0)) {
+		t.Fatalf("NewAuto has %d shards", n)
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	const entries = 1000000
+	keys := make([]string, entries)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	load := func(b *testing.B, newMap func() *SyncMap) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := newMap()
+			for _, key := range keys {
+				m.Set(key, nil)
+			}
+		}
+	}
+	b.Run("NewWithShard", func(b *testing.B) {
+		load(b, func() *SyncMap { return NewWithShard(defaultShardCount) })
+	})
+	b.Run("NewWithCapacity", func(b *testing.B) {
+		load(b, func() *SyncMap { return NewWithCapacity(defaultShardCount, entries) })
+	})
+}
+
+func TestFlushFuncCallsOncePerEntry(t *testing.T) {
+	m := fill(New(), 1000)
+	seen := make(map[string]int)
+	n := m.FlushFunc(func(key string, value interface{}) {
+		seen[key]++
+		if value != mustAtoi(t, key) {
+			t.Errorf("FlushFunc passed %v for key %s", value, key)
+		}
+		m.Set("during", true) // fn runs outside the shard locks
+	})
+	if n != 1000 || len(seen) != 1000 {
+		t.Fatalf("FlushFunc cleared %d entries and called back for %d keys, want 1000", n, len(seen))
+	}
+	for key, calls := range seen {
+		if calls != 1 {
+			t.Fatalf("key %s called back %d times", key, calls)
+		}
+	}
+	if m.Size() != 1 || !m.Has("during") {
+		t.Fatalf("Size() = %d after FlushFunc, want only the key set by fn", m.Size())
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEachKey(t *testing.T) {
+	m := fill(New(), 1000)
+	seen := make(map[string]bool)
+	m.EachKey(func(key string) {
+		if seen[key] {
+			t.Fatalf("EachKey visited %s twice", key)
+		}
+		seen[key] = true
+	})
+	if len(seen) != 1000 {
+		t.Fatalf("EachKey visited %d keys, want 1000", len(seen))
+	}
+	for i := 0; i < 1000; i++ {
+		if !seen[strconv.Itoa(i)] {
+			t.Fatalf("EachKey missed %d", i)
+		}
+	}
+}
+
+func TestGetOrDefault(t *testing.T) {
+	m := New()
+	m.Set("present", 1)
+	m.Set("nil", nil)
+	if value := m.GetOrDefault("present", 2); value != 1 {
+		t.Fatalf("GetOrDefault(present) = %v, want 1", value)
+	}
+	if value := m.GetOrDefault("nil", 2); value != nil {
+		t.Fatalf("GetOrDefault(nil) = %v, want the stored nil", value)
+	}
+	if value := m.GetOrDefault("absent", 2); value != 2 {
+		t.Fatalf("GetOrDefault(absent) = %v, want the default", value)
+	}
+	if m.Has("absent") {
+		t.Fatal("GetOrDefault stored the default")
+	}
+}
+
+func TestAddFloatConcurrent(t *testing.T) {
+	const workers, adds = 8, 500
+	m := New()
+	sums := make([]float64, workers)
+	parallel(workers, func(g int) {
+		r := rand.New(rand.NewSource(int64(g)))
+		for i := 0; i < adds; i++ {
+			delta := r.Float64()*2 - 0.5
+			sums[g] += delta
+			m.AddFloat("latency", delta)
+		}
+	})
+
+	want := 0.0
+	for _, sum := range sums {
+		want += sum
+	}
+	got, _ := m.Get("latency")
+	if math.Abs(got.(float64)-want) > 1e-9*workers*adds {
+		t.Fatalf("AddFloat total = %v, want %v", got, want)
+	}
+}
+
+func TestAddFloatNonFloatPanics(t *testing.T) {
+	m := New()
+	m.Set("k", 1)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("AddFloat on an int value did not panic")
+		}
+		m.Set("k", 2) // the shard lock must have been released
+	}()
+	m.AddFloat("k", 1)
+}
+
+func TestShardRange(t *testing.T) {
+	m := fill(NewWithShard(4), 1000)
+	shard := m.GetShards()[1]
+
+	seen := 0
+	shard.Range(func(key string, value interface{}) bool {
+		if m.Locate(key) != shard || value != mustAtoi(t, key) {
+			t.Fatalf("Range visited %s=%v which is not in this shard", key, value)
+		}
+		seen++
+		return true
+	})
+	if seen != len(shard.GetItems()) || seen == 0 {
+		t.Fatalf("Range visited %d of %d entries", seen, len(shard.GetItems()))
+	}
+
+	visited := 0
+	shard.Range(func(string, interface{}) bool {
+		visited++
+		return visited < 5
+	})
+	if visited != 5 {
+		t.Fatalf("Range went on for %d entries after fn returned false at 5", visited)
+	}
+}
+
+func TestLenAndShardCount(t *testing.T) {
+	m := fill(NewWithShard(100), 250)
+	if m.Len() != 250 || m.Len() != m.Size() {
+		t.Fatalf("Len() = %d, Size() = %d, want 250", m.Len(), m.Size())
+	}
+	if m.ShardCount() != 128 || len(m.GetShards()) != 128 {
+		t.Fatalf("ShardCount() = %d for 100 requested, want 128", m.ShardCount())
+	}
+	if n := New().ShardCount(); n != defaultShardCount {
+		t.Fatalf("New().ShardCount() = %d", n)
+	}
+}
+
+func TestAddAndPruneRefcount(t *testing.T) {
+	const refs = 64
+	m := New()
+	parallel(refs, func(int) {
+		if _, removed := m.AddAndPrune("ref", 1); removed {
+			t.Error("an increment removed the key")
+		}
+	})
+	if value, _ := m.Get("ref"); value != int64(refs) {
+		t.Fatalf("refcount = %v after %d increments", value, refs)
+	}
+
+	var removals int64
+	parallel(refs, func(int) {
+		n, removed := m.AddAndPrune("ref", -1)
+		if removed != (n == 0) {
+			t.Errorf("AddAndPrune returned %d, removed %v", n, removed)
+		}
+		if removed {
+			atomic.AddInt64(&removals, 1)
+		}
+	})
+	if removals != 1 || m.Has("ref") {
+		t.Fatalf("%d removals, key present %v, want exactly one removal at zero", removals, m.Has("ref"))
+	}
+
+	if n, removed := m.AddAndPrune("absent", -1); removed || n != -1 || m.Has("absent") {
+		t.Fatalf("AddAndPrune on an absent key = %d, %v", n, removed)
+	}
+}
+
+func TestShardSnapshotUnion(t *testing.T) {
+	m := fill(NewWithShard(8), 1000)
+	union := make(map[string]interface{})
+	for i := 0; i < m.ShardCount(); i++ {
+		snapshot := m.ShardSnapshot(i)
+		for key, value := range snapshot {
+			if m.ShardIndex(key) != i {
+				t.Fatalf("snapshot of shard %d holds %s from shard %d", i, key, m.ShardIndex(key))
+			}
+			union[key] = value
+		}
+		for key := range snapshot {
+			delete(snapshot, key) // the snapshot is a copy
+		}
+	}
+
+	items := m.Items()
+	if len(union) != len(items) || len(items) != 1000 {
+		t.Fatalf("the snapshots hold %d entries, Items %d", len(union), len(items))
+	}
+	for key, value := range items {
+		if union[key] != value {
+			t.Fatalf("snapshots have %s=%v, Items has %v", key, union[key], value)
+		}
+	}
+}
+
+func TestPopFromDrainsPreferredShardFirst(t *testing.T) {
+	m := fill(NewWithShard(8), 1000)
+	const prefer = 3
+	inPreferred := len(m.ShardSnapshot(prefer))
+
+	for i := 0; i < inPreferred; i++ {
+		key, _, ok := m.PopFrom(prefer)
+		if !ok || m.ShardIndex(key) != prefer {
+			t.Fatalf("PopFrom(%d) #%d took %q from shard %d before draining its own shard", prefer, i, key, m.ShardIndex(key))
+		}
+	}
+	key, _, ok := m.PopFrom(prefer + 8) // taken modulo the shard count
+	if !ok || m.ShardIndex(key) == prefer {
+		t.Fatalf("PopFrom on an empty preferred shard = %q, %v", key, ok)
+	}
+	if m.Size() != 1000-inPreferred-1 {
+		t.Fatalf("Size() = %d", m.Size())
+	}
+	for m.Size() > 0 {
+		if _, _, ok := m.PopFrom(-1); !ok {
+			t.Fatal("PopFrom with a negative hint ran dry on a non empty map")
+		}
+	}
+}
+
+// chiSquared is the chi-squared statistic of the shards index gives to
+// perShard*shards sequential keys against a uniform spread.
+func chiSquared(shards, perShard int, index func(key string) int) float64 {
+	counts := make([]int, shards)
+	for i := 0; i < shards*perShard; i++ {
+		counts[index("user:"+strconv.Itoa(i))]++
+	}
+	chi := 0.0
+	for _, n := range counts {
+		d := float64(n - perShard)
+		chi += d * d / float64(perShard)
+	}
+	return chi
+}
+
+func TestWideHashSpread(t *testing.T) {
+	const shards, perShard = 16384, 20
+	m := NewWithShard(shards)
+	wide := chiSquared(shards, perShard, m.ShardIndex)
+	narrow := chiSquared(shards, perShard, func(key string) int {
+		return int(fnv32(key) & (shards - 1))
+	})
+
+	// for a uniform spread the statistic has a mean of shards-1 and a
+	// standard deviation of sqrt(2*(shards-1)).
+	df := float64(shards - 1)
+	sigma := math.Sqrt(2 * df)
+	if wide > df+6*sigma {
+		t.Fatalf("chi-squared over %d shards = %.0f, want about %.0f +- %.0f", shards, wide, df, sigma)
+	}
+	if wide >= narrow {
+		t.Fatalf("the 64 bit hash (%.0f) spreads no better than fnv32 (%.0f)", wide, narrow)
+	}
+}
+
+func TestRequestedShardCount(t *testing.T) {
+	for _, tc := range []struct{ requested, effective int }{{100, 128}, {128, 128}, {1, 1}, {129, 256}} {
+		m := NewWithShard(tc.requested)
+		if m.RequestedShardCount() != tc.requested || m.ShardCount() != tc.effective {
+			t.Fatalf("NewWithShard(%d): requested %d, effective %d, want %d", tc.requested, m.RequestedShardCount(), m.ShardCount(), tc.effective)
+		}
+		if NextPow2(tc.requested) != tc.effective {
+			t.Fatalf("NextPow2(%d) = %d, want %d", tc.requested, NextPow2(tc.requested), tc.effective)
+		}
+	}
+
+	m := New()
+	m.Resize(100)
+	if m.RequestedShardCount() != 100 || m.ShardCount() != 128 {
+		t.Fatalf("Resize(100): requested %d, effective %d", m.RequestedShardCount(), m.ShardCount())
+	}
+}
+
+func TestFlushShards(t *testing.T) {
+	m := fill(NewWithShard(8), 1000)
+	stats := m.ShardStats()
+	if n := m.FlushShards([]int{1, 5, 1}); n != stats[1]+stats[5] {
+		t.Fatalf("FlushShards removed %d entries, want %d", n, stats[1]+stats[5])
+	}
+	for i, n := range m.ShardStats() {
+		want := stats[i]
+		if i == 1 || i == 5 {
+			want = 0
+		}
+		if n != want {
+			t.Fatalf("shard %d holds %d entries, want %d", i, n, want)
+		}
+	}
+
+	for _, indices := range [][]int{{-1}, {2, 8}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("FlushShards(%v) did not panic", indices)
+				}
+			}()
+			m.FlushShards(indices)
+		}()
+		if m.ShardStats()[2] != stats[2] {
+			t.Fatalf("FlushShards(%v) cleared shard 2 before panicking", indices)
+		}
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	src := make(map[string]interface{}, 1000)
+	for i := 0; i < 1000; i++ {
+		src[strconv.Itoa(i)] = i
+	}
+	m := FromMap(16, src)
+	if m.Size() != len(src) || m.ShardCount() != 16 {
+		t.Fatalf("FromMap holds %d entries in %d shards, want %d in 16", m.Size(), m.ShardCount(), len(src))
+	}
+	for key, value := range m.Items() {
+		if src[key] != value {
+			t.Fatalf("FromMap has %s=%v, source has %v", key, value, src[key])
+		}
+	}
+	delete(src, "0")
+	if !m.Has("0") {
+		t.Fatal("FromMap shares its entries with the source")
+	}
+}
+
+func TestDeleteAndReturn(t *testing.T) {
+	m := New()
+	m.Set("k", "old")
+	var evicted []string
+	m.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+
+	if value, ok := m.DeleteAndReturn("k"); !ok || value != "old" {
+		t.Fatalf("DeleteAndReturn(k) = %v, %v, want old, true", value, ok)
+	}
+	if m.Has("k") {
+		t.Fatal("DeleteAndReturn left the key")
+	}
+	if value, ok := m.DeleteAndReturn("k"); ok || value != nil {
+		t.Fatalf("DeleteAndReturn of an absent key = %v, %v", value, ok)
+	}
+	if len(evicted) != 1 || evicted[0] != "k" {
+		t.Fatalf("evicted %v, want the single removal reported", evicted)
+	}
+}
+
+func TestMapShardsSizes(t *testing.T) {
+	m := fill(NewWithShard(8), 1000)
+	results := m.MapShards(func(shardIndex int, items map[string]interface{}) interface{} {
+		for key := range items {
+			if m.ShardIndex(key) != shardIndex {
+				t.Errorf("shard %d was handed %s", shardIndex, key)
+			}
+			m.Delete(key) // fn gets a copy and holds no lock
+		}
+		return len(items)
+	})
+	if len(results) != 8 {
+		t.Fatalf("MapShards returned %d results for 8 shards", len(results))
+	}
+	sum := 0
+	for _, n := range results {
+		sum += n.(int)
+	}
+	if sum != 1000 || !m.IsEmpty() {
+		t.Fatalf("shard sizes sum to %d, want 1000", sum)
+	}
+}
+
+func TestUpsertAccumulates(t *testing.T) {
+	m := New()
+	insert := func() interface{} { return []int{0} }
+	parallel(8, func(g int) {
+		m.Upsert("log", insert, func(old interface{}) interface{} {
+			return append(old.([]int), g+1)
+		})
+	})
+
+	value, _ := m.Get("log")
+	entries := value.([]int)
+	if len(entries) != 8 || entries[0] != 0 {
+		t.Fatalf("log = %v, want the seed followed by 7 appends", entries)
+	}
+	if got := m.Upsert("log", insert, func(old interface{}) interface{} {
+		return append(old.([]int), 100)
+	}); len(got.([]int)) != 9 {
+		t.Fatalf("Upsert returned %v, want the stored slice", got)
+	}
+}
+
+func TestGetShardsSnapshot(t *testing.T) {
+	m := fill(NewWithShard(16), 1000)
+	snapshots := m.GetShardsSnapshot()
+	if len(snapshots) != m.ShardCount() {
+		t.Fatalf("%d snapshots for %d shards", len(snapshots), m.ShardCount())
+	}
+
+	total := 0
+	for i, snapshot := range snapshots {
+		total += len(snapshot)
+		for key, value := range snapshot {
+			if m.ShardIndex(key) != i || value != mustAtoi(t, key) {
+				t.Fatalf("snapshot %d holds %s=%v", i, key, value)
+			}
+		}
+	}
+	if total != 1000 {
+		t.Fatalf("the snapshots hold %d entries, want 1000", total)
+	}
+
+	m.Flush()
+	if len(snapshots[0]) == 0 && len(snapshots[1]) == 0 {
+		t.Fatal("the snapshots are not detached from the shards")
+	}
+}
@@ -0,0 +1,70 @@
+package syncmap
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLockTimeout is returned by TryGet when the shard lock stays held past
+// the timeout.
+var ErrLockTimeout = errors.New("syncmap: shard lock timeout")
+
+const tryLockInterval = 50 * time.Microsecond
+
+// TryGet is Get giving up with ErrLockTimeout when the shard of key can't be
+// locked within timeout, so a long running callback holding the shard
+// applies backpressure instead of blocking the caller indefinitely. Waiting
+// for a Resize to publish its new shards counts against the same timeout.
+func (m *SyncMap) TryGet(key string, timeout time.Duration) (interface{}, bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		shard := m.route(key)
+		if shard.retired.Load() {
+			if !tryUntil(m.mu.TryRLock, deadline) {
+				return nil, false, ErrLockTimeout
+			}
+			m.mu.RUnlock()
+			continue
+		}
+		if shard.view != nil {
+			v, ok := shard.getFromView(key)
+			if shard.retired.Load() {
+				continue
+			}
+			return v, ok, nil
+		}
+
+		touch := shard.lru != nil || shard.meta != nil
+		tryLock, unlock := shard.TryRLock, shard.RUnlock
+		if touch {
+			tryLock, unlock = shard.TryLock, shard.RWMutex.Unlock
+		}
+		if !tryUntil(tryLock, deadline) {
+			return nil, false, ErrLockTimeout
+		}
+		if shard.retired.Load() {
+			unlock()
+			continue
+		}
+
+		if !touch {
+			v, ok := shard.GetNotLock(key)
+			shard.RUnlock()
+			return v, ok, nil
+		}
+		v, ok := shard.getAndTouchNotLock(key)
+		shard.Unlock()
+		return v, ok, nil
+	}
+}
+
+// tryUntil calls tryLock until it succeeds or deadline passes.
+func tryUntil(tryLock func() bool, deadline time.Time) bool {
+	for !tryLock() {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(tryLockInterval)
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package syncmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTryGetTimesOut(t *testing.T) {
+	for name, m := range map[string]*SyncMap{"plain": New(), "lru": NewLRU(10, 1)} {
+		m.Set("k", 1)
+		shard := m.Locate("k")
+
+		shard.Lock()
+		start := time.Now()
+		_, _, err := m.TryGet("k", 5*time.Millisecond)
+		elapsed := time.Since(start)
+		shard.RWMutex.Unlock()
+
+		if err != ErrLockTimeout {
+			t.Fatalf("%s: TryGet on a write locked shard returned %v", name, err)
+		}
+		if elapsed < 5*time.Millisecond || elapsed > time.Second {
+			t.Fatalf("%s: TryGet gave up after %v", name, elapsed)
+		}
+		if value, ok, err := m.TryGet("k", time.Millisecond); err != nil || !ok || value != 1 {
+			t.Fatalf("%s: TryGet on a free shard = %v, %v, %v", name, value, ok, err)
+		}
+	}
+}
+
+func TestTryGetTimesOutDuringResize(t *testing.T) {
+	m := New()
+	m.Set("k", 1)
+	shard := m.Locate("k")
+
+	// the state of a Resize that moved the shard of k but did not publish
+	// the new shards yet.
+	m.mu.Lock()
+	shard.retired.Store(true)
+	_, _, err := m.TryGet("k", 5*time.Millisecond)
+	shard.retired.Store(false)
+	m.mu.Unlock()
+
+	if err != ErrLockTimeout {
+		t.Fatalf("TryGet while waiting for a Resize returned %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package syncmap
+
+import (
+	"time"
+)
+
+// NoExpiration is the ttl GetWithTTL reports for keys that never expire.
+const NoExpiration time.Duration = -1
+
+// NewWithExpiration starts a janitor that removes expired keys every
+// cleanupInterval, call Close to stop it.
+func NewWithExpiration(cleanupInterval time.Duration) *SyncMap {
+	m := New()
+	if cleanupInterval > 0 {
+		m.stopJanitor = make(chan struct{})
+		go m.runJanitor(cleanupInterval)
+	}
+	return m
+}
+
+// SetWithTTL stores value until ttl elapses, a non positive ttl never expires.
+// Get treats expired keys as absent, but Size and the iterators may still see
+// them until they are read or swept by the janitor.
+func (m *SyncMap) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	shard := m.lockKey(key)
+	shard.SetNotLock(key, value)
+	shard.setExpiryNotLock(key, ttl)
+	shard.Unlock()
+}
+
+// Touch restarts the ttl of key without changing its value and reports
+// whether key was present and not yet expired. A non positive ttl makes the
+// key permanent, like SetWithTTL.
+func (m *SyncMap) Touch(key string, ttl time.Duration) bool {
+	shard := m.lockKey(key)
+	_, ok := shard.GetNotLock(key)
+	if ok {
+		shard.setExpiryNotLock(key, ttl)
+	}
+	shard.Unlock()
+	return ok
+}
+
+// GetWithTTL returns the value of key with its remaining ttl, which is
+// NoExpiration for keys stored without one.
+func (m *SyncMap) GetWithTTL(key string) (value interface{}, ttl time.Duration, ok bool) {
+	shard := m.rlockKey(key)
+	defer shard.RUnlock()
+
+	now := time.Now().UnixNano()
+	value, ok = shard.items[key]
+	if !ok || shard.expiredNotLock(key, now) {
+		return nil, 0, false
+	}
+	deadline, ok := shard.expires[key]
+	if !ok {
+		return value, NoExpiration, true
+	}
+	return value, time.Duration(deadline - now), true
+}
+
+func (m *SyncMap) DeleteExpired() int {
+	var (
+		removed int
+		evicted []Item
+	)
+	m.mu.RLock()
+	for _, shard := range m.shards {
+		shard.Lock()
+		removed += shard.deleteExpiredNotLock(time.Now().UnixNano())
+		evicted = shard.unlockDeferred(evicted)
+	}
+	m.mu.RUnlock()
+	m.hooks.notify(evicted)
+	return removed
+}
+
+func (m *SyncMap) Close() {
+	m.closeOnce.Do(func() {
+		if m.stopJanitor != nil {
+			close(m.stopJanitor)
+		}
+	})
+}
+
+func (m *SyncMap) runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.DeleteExpired()
+		case <-m.stopJanitor:
+			return
+		}
+	}
+}
+
+func (sd *ShardMap) setExpiryNotLock(key string, ttl time.Duration) {
+	var deadline int64
+	if ttl > 0 {
+		deadline = time.Now().Add(ttl).UnixNano()
+	}
+	sd.setDeadlineNotLock(key, deadline)
+}
+
+// setDeadlineNotLock sets the unix nano expiry of key, zero removes it.
+func (sd *ShardMap) setDeadlineNotLock(key string, deadline int64) {
+	if sd.view != nil {
+		sd.view.invalidate()
+	}
+	if deadline == 0 {
+		if sd.expires != nil {
+			delete(sd.expires, key)
+		}
+		return
+	}
+	if sd.expires == nil {
+		sd.expires = make(map[string]int64)
+	}
+	sd.expires[key] = deadline
+}
+
+func (sd *ShardMap) expiredNotLock(key string, now int64) bool {
+	if sd.expires == nil {
+		return false
+	}
+	deadline, ok := sd.expires[key]
+	return ok && deadline <= now
+}
+
+// deleteExpired rechecks the deadline under the write lock, the key may have
+// been refreshed after the caller released its read lock. A shard retired
+// meanwhile is left alone, the key lives on in the new shards.
+func (sd *ShardMap) deleteExpired(key string) {
+	sd.Lock()
+	if !sd.retired.Load() && sd.expiredNotLock(key, time.Now().UnixNano()) {
+		sd.DeleteNotLock(key)
+	}
+	sd.Unlock()
+}
+
+func (sd *ShardMap) deleteExpiredNotLock(now int64) int {
+	removed := 0
+	for key, deadline := range sd.expires {
+		if deadline <= now {
+			sd.DeleteNotLock(key)
+			removed++
+		}
+	}
+	return removed
+}
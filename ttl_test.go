@@ -0,0 +1,112 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTTLLazyExpiry(t *testing.T) {
+	m := New()
+	m.SetWithTTL("short", 1, 10*time.Millisecond)
+	m.SetWithTTL("long", 2, time.Hour)
+	m.SetWithTTL("forever", 3, 0)
+	if v, ok := m.Get("short"); !ok || v != 1 {
+		t.Fatalf("Get(short) before expiry = %v, %v", v, ok)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if m.Size() != 3 {
+		t.Fatalf("Size() = %d before the expired key was read, want 3", m.Size())
+	}
+	if _, ok := m.Get("short"); ok {
+		t.Fatal("Get returned an expired key")
+	}
+	if m.Size() != 2 {
+		t.Fatalf("Size() = %d after reading the expired key, want 2", m.Size())
+	}
+	if !m.Has("long") || !m.Has("forever") {
+		t.Fatal("live keys were dropped")
+	}
+}
+
+func TestTTLJanitorSweep(t *testing.T) {
+	m := NewWithExpiration(5 * time.Millisecond)
+	defer m.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		m.SetWithTTL(key, key, 10*time.Millisecond)
+	}
+	m.Set("kept", true)
+
+	deadline := time.Now().Add(time.Second)
+	for m.Size() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d entries, want 1", m.Size())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !m.Has("kept") {
+		t.Fatal("janitor removed a key without ttl")
+	}
+	m.Close()
+	m.Close()
+}
+
+func TestPopSkipsExpired(t *testing.T) {
+	m := NewWithShard(1)
+	for i := 0; i < 10; i++ {
+		m.SetWithTTL(strconv.Itoa(i), i, time.Nanosecond)
+	}
+	m.Set("live", true)
+	time.Sleep(time.Millisecond)
+	if key, _, ok := m.Pop(); !ok || key != "live" {
+		t.Fatalf("Pop() = %q, %v, want the only live key", key, ok)
+	}
+	if _, _, ok := m.Pop(); ok || m.Size() != 0 {
+		t.Fatal("Pop returned an expired entry")
+	}
+}
+
+func TestTouchExtendsExpiry(t *testing.T) {
+	m := New()
+	m.SetWithTTL("session", "alive", 20*time.Millisecond)
+	m.SetWithTTL("stale", "gone", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if !m.Touch("session", time.Hour) {
+		t.Fatal("Touch missed a live key")
+	}
+	if m.Touch("stale", time.Hour) || m.Touch("missing", time.Hour) {
+		t.Fatal("Touch reported an expired or missing key")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if value, ok := m.Get("session"); !ok || value != "alive" {
+		t.Fatalf("touched key = %v, %v after its original expiry", value, ok)
+	}
+	if m.Has("stale") {
+		t.Fatal("Touch revived an expired key")
+	}
+}
+
+func TestGetWithTTL(t *testing.T) {
+	m := New()
+	m.SetWithTTL("short", 1, time.Minute)
+	m.Set("forever", 2)
+	m.SetWithTTL("expired", 3, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	value, ttl, ok := m.GetWithTTL("short")
+	if !ok || value != 1 || ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Fatalf("GetWithTTL(short) = %v, %v, %v, want about a minute left", value, ttl, ok)
+	}
+	if value, ttl, ok := m.GetWithTTL("forever"); !ok || value != 2 || ttl != NoExpiration {
+		t.Fatalf("GetWithTTL(forever) = %v, %v, %v, want NoExpiration", value, ttl, ok)
+	}
+	if _, _, ok := m.GetWithTTL("expired"); ok {
+		t.Fatal("GetWithTTL returned an expired key")
+	}
+	if _, _, ok := m.GetWithTTL("missing"); ok {
+		t.Fatal("GetWithTTL returned a missing key")
+	}
+}
@@ -0,0 +1,125 @@
+package syncmap
+
+import (
+	"sort"
+)
+
+// Txn gives a Transact callback access to the keys of the transaction.
+type Txn struct {
+	shards map[string]*ShardMap
+}
+
+func (t *Txn) shard(key string) *ShardMap {
+	shard, ok := t.shards[key]
+	if !ok {
+		panic("syncmap: key " + key + " is not part of the transaction")
+	}
+	return shard
+}
+
+func (t *Txn) Get(key string) (interface{}, bool) {
+	return t.shard(key).GetNotLock(key)
+}
+
+func (t *Txn) Set(key string, value interface{}) {
+	t.shard(key).SetNotLock(key, value)
+}
+
+func (t *Txn) Delete(key string) {
+	t.shard(key).DeleteNotLock(key)
+}
+
+// Transact runs fn with the shards of keys write locked, so its reads and
+// writes of those keys are atomic as a whole. Shards are locked in ascending
+// index order, concurrent transactions can't deadlock each other. Using a
+// key outside keys panics, and fn must not call back into the map.
+func (m *SyncMap) Transact(keys []string, fn func(txn *Txn)) {
+	txn := &Txn{shards: make(map[string]*ShardMap, len(keys))}
+
+	m.mu.RLock()
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		idx := m.locateIndex(key)
+		txn.shards[key] = m.shards[idx]
+		indexes = append(indexes, idx)
+	}
+	indexes = uniqueSorted(indexes)
+	for _, idx := range indexes {
+		m.shards[idx].Lock()
+	}
+	shards := m.shards
+	m.mu.RUnlock()
+
+	defer func() {
+		var evicted []Item
+		for i := len(indexes) - 1; i >= 0; i-- {
+			evicted = shards[indexes[i]].unlockDeferred(evicted)
+		}
+		m.hooks.notify(evicted)
+	}()
+	fn(txn)
+}
+
+// WithKeyLock runs fn with the shard of key write locked, fn reads and writes
+// key through the *NotLock methods of sd to make a read-modify-write atomic.
+// Calling back into the map from fn deadlocks on that shard, and so may
+// waiting on another goroutine that needs it, use Transact to cover several
+// keys instead.
+func (m *SyncMap) WithKeyLock(key string, fn func(sd *ShardMap)) {
+	shard := m.lockKey(key)
+	defer shard.Unlock()
+	fn(shard)
+}
+
+// Rename atomically moves the value and the TTL of oldKey to newKey and
+// reports false when oldKey is missing. An existing newKey is overwritten
+// like Set would, without reporting its old value to OnEvicted. Both shards
+// are locked in ascending index order, as in Transact.
+func (m *SyncMap) Rename(oldKey, newKey string) bool {
+	if oldKey == newKey {
+		return m.Has(oldKey)
+	}
+
+	m.mu.RLock()
+	srcIdx, dstIdx := m.locateIndex(oldKey), m.locateIndex(newKey)
+	src, dst := m.shards[srcIdx], m.shards[dstIdx]
+	if srcIdx > dstIdx {
+		dst.Lock()
+		src.Lock()
+	} else {
+		src.Lock()
+		if dstIdx != srcIdx {
+			dst.Lock()
+		}
+	}
+	m.mu.RUnlock()
+
+	value, ok := src.GetNotLock(oldKey)
+	if ok {
+		deadline := src.expires[oldKey]
+		src.takeNotLock(oldKey)
+		dst.SetNotLock(newKey, value)
+		if _, kept := dst.items[newKey]; kept && deadline != 0 {
+			dst.setDeadlineNotLock(newKey, deadline)
+		}
+	}
+
+	var evicted []Item
+	if dstIdx != srcIdx {
+		evicted = dst.unlockDeferred(evicted)
+	}
+	evicted = src.unlockDeferred(evicted)
+	m.hooks.notify(evicted)
+	return ok
+}
+
+func uniqueSorted(ints []int) []int {
+	sort.Ints(ints)
+	out := ints[:0]
+	for i, n := range ints {
+		if i == 0 || n != ints[i-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package syncmap
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTransactTransfersKeepSum(t *testing.T) {
+	const accounts, balance = 8, 1000
+	m := NewWithShard(4)
+	for i := 0; i < accounts; i++ {
+		m.Set(strconv.Itoa(i), balance)
+	}
+
+	parallel(8, func(g int) {
+		r := rand.New(rand.NewSource(int64(g)))
+		for i := 0; i < 500; i++ {
+			from, to := strconv.Itoa(r.Intn(accounts)), strconv.Itoa(r.Intn(accounts))
+			if from == to {
+				continue
+			}
+			m.Transact([]string{from, to}, func(txn *Txn) {
+				a, _ := txn.Get(from)
+				b, _ := txn.Get(to)
+				amount := r.Intn(10)
+				txn.Set(from, a.(int)-amount)
+				txn.Set(to, b.(int)+amount)
+			})
+		}
+	})
+
+	sum := 0
+	for i := 0; i < accounts; i++ {
+		value, _ := m.Get(strconv.Itoa(i))
+		sum += value.(int)
+	}
+	if sum != accounts*balance {
+		t.Fatalf("balances sum to %d, want %d", sum, accounts*balance)
+	}
+}
+
+func TestTransactPanicsOnForeignKey(t *testing.T) {
+	m := New()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("using a key outside the transaction did not panic")
+		}
+		m.Set("a", 1)
+	}()
+	m.Transact([]string{"a"}, func(txn *Txn) {
+		txn.Get("b")
+	})
+}
+
+func TestRename(t *testing.T) {
+	m := NewWithShard(8)
+	// find a key sharing the shard of "a" and one living elsewhere.
+	same, other := "", ""
+	for i := 0; same == "" || other == ""; i++ {
+		key := "b" + strconv.Itoa(i)
+		if m.ShardIndex(key) == m.ShardIndex("a") {
+			same = key
+		} else {
+			other = key
+		}
+	}
+
+	for _, newKey := range []string{same, other} {
+		m.SetWithTTL("a", "value", time.Hour)
+		m.Set(newKey, "overwritten")
+		if !m.Rename("a", newKey) {
+			t.Fatalf("Rename(a, %s) missed a present key", newKey)
+		}
+		if m.Has("a") || m.Size() != 1 {
+			t.Fatalf("Rename(a, %s) left %d entries", newKey, m.Size())
+		}
+		value, ttl, ok := m.GetWithTTL(newKey)
+		if !ok || value != "value" || ttl <= 0 {
+			t.Fatalf("%s = %v, %v, %v after Rename, want the value and ttl of a", newKey, value, ttl, ok)
+		}
+		m.Delete(newKey)
+	}
+
+	if m.Rename("missing", other) || m.Has(other) {
+		t.Fatal("Rename of a missing key created the new key")
+	}
+	m.Set("a", 1)
+	if !m.Rename("a", "a") || m.Rename("missing", "missing") {
+		t.Fatal("Rename to the same key does not report presence")
+	}
+}
+
+func TestWithKeyLockReadModifyWrite(t *testing.T) {
+	m := New()
+	m.Set("n", 0)
+	parallel(8, func(int) {
+		for i := 0; i < 100; i++ {
+			m.WithKeyLock("n", func(sd *ShardMap) {
+				value, _ := sd.GetNotLock("n")
+				sd.SetNotLock("n", value.(int)+1)
+			})
+		}
+	})
+	if value, _ := m.Get("n"); value != 800 {
+		t.Fatalf("n = %v after 800 locked increments", value)
+	}
+
+	m.WithKeyLock("new", func(sd *ShardMap) {
+		if _, ok := sd.GetNotLock("new"); !ok {
+			sd.SetNotLock("new", "created")
+		}
+	})
+	if value, _ := m.Get("new"); value != "created" {
+		t.Fatalf("new = %v", value)
+	}
+}
@@ -0,0 +1,21 @@
+package syncmap
+
+// GetInt returns the value of key if it is an int, ok is false when key is
+// missing or holds another type. GetString and GetBool behave the same.
+func (m *SyncMap) GetInt(key string) (int, bool) {
+	value, _ := m.Get(key)
+	n, ok := value.(int)
+	return n, ok
+}
+
+func (m *SyncMap) GetString(key string) (string, bool) {
+	value, _ := m.Get(key)
+	s, ok := value.(string)
+	return s, ok
+}
+
+func (m *SyncMap) GetBool(key string) (bool, bool) {
+	value, _ := m.Get(key)
+	b, ok := value.(bool)
+	return b, ok
+}
@@ -0,0 +1,39 @@
+package syncmap
+
+import (
+	"testing"
+)
+
+func TestTypedGetters(t *testing.T) {
+	m := New()
+	m.Set("int", 1)
+	m.Set("string", "s")
+	m.Set("bool", true)
+	m.Set("int64", int64(1))
+
+	if n, ok := m.GetInt("int"); !ok || n != 1 {
+		t.Fatalf("GetInt(int) = %v, %v", n, ok)
+	}
+	if s, ok := m.GetString("string"); !ok || s != "s" {
+		t.Fatalf("GetString(string) = %q, %v", s, ok)
+	}
+	if b, ok := m.GetBool("bool"); !ok || !b {
+		t.Fatalf("GetBool(bool) = %v, %v", b, ok)
+	}
+
+	for _, key := range []string{"string", "bool", "int64", "missing"} {
+		if n, ok := m.GetInt(key); ok || n != 0 {
+			t.Fatalf("GetInt(%s) = %v, %v, want 0, false", key, n, ok)
+		}
+	}
+	for _, key := range []string{"int", "bool", "missing"} {
+		if s, ok := m.GetString(key); ok || s != "" {
+			t.Fatalf("GetString(%s) = %q, %v, want \"\", false", key, s, ok)
+		}
+	}
+	for _, key := range []string{"int", "string", "missing"} {
+		if b, ok := m.GetBool(key); ok || b {
+			t.Fatalf("GetBool(%s) = %v, %v, want false, false", key, b, ok)
+		}
+	}
+}
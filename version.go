@@ -0,0 +1,28 @@
+package syncmap
+
+// SetIfNewer stores value under key tagged with version when key is missing,
+// was stored without a version or holds an older one, and reports whether it
+// did so. Equal versions are rejected, so of several writers racing on a key
+// the highest version wins whatever their order.
+func (m *SyncMap) SetIfNewer(key string, value interface{}, version uint64) bool {
+	shard := m.lockKey(key)
+	defer shard.Unlock()
+
+	if _, ok := shard.GetNotLock(key); ok {
+		if current, tagged := shard.versions[key]; tagged && version <= current {
+			return false
+		}
+	}
+	shard.SetNotLock(key, value)
+	if _, ok := shard.items[key]; ok {
+		shard.setVersionNotLock(key, version)
+	}
+	return true
+}
+
+func (sd *ShardMap) setVersionNotLock(key string, version uint64) {
+	if sd.versions == nil {
+		sd.versions = make(map[string]uint64)
+	}
+	sd.versions[key] = version
+}
@@ -0,0 +1,64 @@
+package syncmap
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSetIfNewerHighestWins(t *testing.T) {
+	const writers, versions = 8, 200
+	m := New()
+	parallel(writers, func(g int) {
+		r := rand.New(rand.NewSource(int64(g)))
+		for _, v := range r.Perm(versions) {
+			version := uint64(v + 1)
+			m.SetIfNewer("k", version, version)
+		}
+	})
+	if value, _ := m.Get("k"); value != uint64(versions) {
+		t.Fatalf("k = %v after out of order writes, want version %d", value, versions)
+	}
+
+	if m.SetIfNewer("k", "stale", versions) || m.SetIfNewer("k", "older", 1) {
+		t.Fatal("SetIfNewer accepted a version that is not newer")
+	}
+	if !m.SetIfNewer("k", "newer", versions+1) {
+		t.Fatal("SetIfNewer rejected a newer version")
+	}
+	if !m.SetIfNewer("missing", "v", 0) {
+		t.Fatal("SetIfNewer rejected a missing key")
+	}
+	m.Set("k", "plain")
+	if !m.SetIfNewer("k", "tagged", 1) {
+		t.Fatal("SetIfNewer rejected a key stored without a version")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	m := New()
+	last := m.Version()
+	step := func(name string, mutate func()) {
+		mutate()
+		if v := m.Version(); v <= last {
+			t.Fatalf("Version() = %d after %s, want above %d", v, name, last)
+		}
+		last = m.Version()
+	}
+
+	step("Set", func() { m.Set("a", 1) })
+	step("overwrite", func() { m.Set("a", 2) })
+	step("MSet", func() { m.MSet(map[string]interface{}{"b": 1, "c": 2}) })
+	step("Delete", func() { m.Delete("a") })
+	step("Flush", func() { m.Flush() })
+
+	m.Set("d", 1)
+	last = m.Version()
+	m.Get("d")
+	m.Has("d")
+	m.Keys()
+	m.Size()
+	m.Items()
+	if m.Version() != last {
+		t.Fatal("Version changed across reads")
+	}
+}
@@ -0,0 +1,71 @@
+package syncmap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// NewCOW serves Get from an immutable copy of each shard loaded through an
+// atomic pointer, reads take no lock, not even to find the shard, unless
+// they drop an expired entry. Every write section copies the whole shard
+// before releasing its lock, so writes cost O(shard size): use it for maps
+// that are read far more often than written, and prefer the batch methods
+// which copy once per shard.
+func NewCOW(shardCount int) *SyncMap {
+	m := new(SyncMap)
+	m.cow = true
+	m.init(shardCount, nil)
+	return m
+}
+
+// cowView is the copy of a shard that Get reads without taking the shard
+// lock. Writes mark it dirty under the write lock and publish rebuilds it
+// before the lock is released, items stays the source of truth for
+// everything else.
+type cowView struct {
+	snapshot atomic.Pointer[map[string]viewEntry]
+	dirty    bool
+}
+
+type viewEntry struct {
+	value    interface{}
+	deadline int64
+}
+
+func (sd *ShardMap) getFromView(key string) (interface{}, bool) {
+	entry, ok := sd.view.load(key)
+	if !ok {
+		return nil, false
+	}
+	if entry.deadline > 0 && entry.deadline <= time.Now().UnixNano() {
+		sd.deleteExpired(key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (v *cowView) load(key string) (viewEntry, bool) {
+	snapshot := v.snapshot.Load()
+	if snapshot == nil {
+		return viewEntry{}, false
+	}
+	entry, ok := (*snapshot)[key]
+	return entry, ok
+}
+
+func (v *cowView) invalidate() {
+	v.dirty = true
+}
+
+// publish runs before the shard write lock is released.
+func (v *cowView) publish(sd *ShardMap) {
+	if !v.dirty {
+		return
+	}
+	snapshot := make(map[string]viewEntry, len(sd.items))
+	for key, value := range sd.items {
+		snapshot[key] = viewEntry{value, sd.expires[key]}
+	}
+	v.snapshot.Store(&snapshot)
+	v.dirty = false
+}
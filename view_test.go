@@ -0,0 +1,70 @@
+package syncmap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func BenchmarkReadOnly(b *testing.B) {
+	b.Run("RWMutex", func(b *testing.B) { benchmarkReads(b, New(), 0) })
+	b.Run("COW", func(b *testing.B) { benchmarkReads(b, NewCOW(defaultShardCount), 0) })
+}
+
+func TestCOWInterleavedReadsWrites(t *testing.T) {
+	m := NewCOW(8)
+	parallel(4, func(g int) {
+		for i := 0; i < 500; i++ {
+			key := strconv.Itoa(g) + "-" + strconv.Itoa(i)
+			m.Set(key, i)
+			if value, ok := m.Get(key); !ok || value != i {
+				t.Errorf("Get(%s) = %v, %v right after Set", key, value, ok)
+			}
+			if i%2 == 0 {
+				m.Delete(key)
+				if m.Has(key) {
+					t.Errorf("Has(%s) after Delete", key)
+				}
+			}
+		}
+	})
+	if m.Size() != 4*250 {
+		t.Fatalf("Size() = %d, want %d", m.Size(), 4*250)
+	}
+}
+
+func TestCOWFollowsBulkWrites(t *testing.T) {
+	m := NewCOW(8)
+	m.MSet(map[string]interface{}{"a": 1, "b": 2})
+	m.SetWithTTL("ttl", 3, time.Millisecond)
+	if value, _ := m.Get("b"); value != 2 || !m.Has("ttl") {
+		t.Fatal("Get missed keys written by MSet or SetWithTTL")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if m.Has("ttl") {
+		t.Fatal("Get returned an expired key")
+	}
+
+	m.Resize(32)
+	if value, _ := m.Get("a"); value != 1 {
+		t.Fatalf("Get(a) after Resize = %v", value)
+	}
+	m.Flush()
+	if _, ok := m.Get("a"); ok {
+		t.Fatal("Get returned a flushed key")
+	}
+}
+
+func TestCOWGetTakesNoLock(t *testing.T) {
+	m := NewCOW(1)
+	m.Set("k", 1)
+	shard := m.Locate("k")
+	m.mu.Lock()
+	shard.Lock()
+	value, ok := m.Get("k")
+	shard.RWMutex.Unlock()
+	m.mu.Unlock()
+	if !ok || value != 1 {
+		t.Fatalf("Get(k) = %v, %v with the locks held", value, ok)
+	}
+}
@@ -0,0 +1,111 @@
+package syncmap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type Op int
+
+const (
+	OpSet Op = iota + 1
+	OpDelete
+)
+
+// ChangeEvent describes a mutation, Value is the new value of a set and
+// OldValue the value a set replaced or a delete removed.
+type ChangeEvent struct {
+	Key      string
+	Op       Op
+	Value    interface{}
+	OldValue interface{}
+}
+
+type watcher struct {
+	mu     sync.Mutex
+	ch     chan ChangeEvent
+	closed bool
+}
+
+type watchers struct {
+	mu   sync.Mutex
+	list atomic.Pointer[[]*watcher]
+}
+
+// NewWatchable returns a map that supports Watch, the other constructors skip
+// the event bookkeeping entirely.
+func NewWatchable() *SyncMap {
+	m := New()
+	m.hooks.watch = new(watchers)
+	return m
+}
+
+// Watch subscribes to the changes made by Set, Delete, Flush, expiry and
+// every other mutation, events of one key arrive in order. Delivery never
+// blocks the map: events are dropped while the channel buffer is full.
+// Calling the returned func unsubscribes and closes the channel. Watch panics
+// on a map not built by NewWatchable.
+func (m *SyncMap) Watch(buffer int) (<-chan ChangeEvent, func()) {
+	ws := m.hooks.watch
+	if ws == nil {
+		panic("syncmap: Watch on a map not built by NewWatchable")
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	w := &watcher{ch: make(chan ChangeEvent, buffer)}
+	ws.mu.Lock()
+	list := append(ws.load(), w)
+	ws.list.Store(&list)
+	ws.mu.Unlock()
+
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			ws.remove(w)
+			w.mu.Lock()
+			w.closed = true
+			close(w.ch)
+			w.mu.Unlock()
+		})
+	}
+}
+
+func (ws *watchers) load() []*watcher {
+	if list := ws.list.Load(); list != nil {
+		return *list
+	}
+	return nil
+}
+
+func (ws *watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	old := ws.load()
+	list := make([]*watcher, 0, len(old))
+	for _, other := range old {
+		if other != w {
+			list = append(list, other)
+		}
+	}
+	ws.list.Store(&list)
+}
+
+func (h *shardHooks) watching() bool {
+	return h != nil && h.watch != nil && len(h.watch.load()) > 0
+}
+
+func (h *shardHooks) publish(event ChangeEvent) {
+	for _, w := range h.watch.load() {
+		w.mu.Lock()
+		if !w.closed {
+			select {
+			case w.ch <- event:
+			default:
+			}
+		}
+		w.mu.Unlock()
+	}
+}
@@ -0,0 +1,65 @@
+package syncmap
+
+import (
+	"testing"
+)
+
+func TestWatchEvents(t *testing.T) {
+	m := NewWatchable()
+	ch, unsubscribe := m.Watch(16)
+
+	m.Set("a", 1)
+	m.Set("a", 2)
+	m.Delete("a")
+	m.Delete("missing")
+	m.Set("b", 3)
+	m.Flush()
+
+	want := []ChangeEvent{
+		{Key: "a", Op: OpSet, Value: 1},
+		{Key: "a", Op: OpSet, Value: 2, OldValue: 1},
+		{Key: "a", Op: OpDelete, OldValue: 2},
+		{Key: "b", Op: OpSet, Value: 3},
+		{Key: "b", Op: OpDelete, OldValue: 3},
+	}
+	for i, w := range want {
+		if got := <-ch; got != w {
+			t.Fatalf("event %d = %+v, want %+v", i, got, w)
+		}
+	}
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %+v", event)
+	default:
+	}
+
+	unsubscribe()
+	unsubscribe()
+	m.Set("c", 4)
+	if event, ok := <-ch; ok {
+		t.Fatalf("event %+v delivered after unsubscribe", event)
+	}
+}
+
+func TestWatchDropsWhenFull(t *testing.T) {
+	m := NewWatchable()
+	ch, unsubscribe := m.Watch(1)
+	defer unsubscribe()
+	m.Set("a", 1)
+	m.Set("a", 2)
+	if event := <-ch; event.Value != 1 {
+		t.Fatalf("first event %+v, want the set of 1", event)
+	}
+	if len(ch) != 0 {
+		t.Fatal("an event was queued past the buffer")
+	}
+}
+
+func TestWatchPanicsWithoutNewWatchable(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Watch on a plain map did not panic")
+		}
+	}()
+	New().Watch(1)
+}